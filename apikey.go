@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+const (
+	apiKeyPrefix = "gator_"
+	apiKeyEnv    = "GATOR_API_KEY"
+)
+
+// newAPIKey returns a random API key and the hash that is stored in its
+// place. Keys are 256 random bits, so a plain SHA-256 is enough to make a
+// leaked users table useless.
+func newAPIKey() (key string, hash sql.NullString, err error) {
+	raw := make([]byte, 32)
+	if _, err = rand.Read(raw); err != nil {
+		return "", sql.NullString{}, err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(raw)
+	return key, hashAPIKey(key), nil
+}
+
+func hashAPIKey(key string) sql.NullString {
+	sum := sha256.Sum256([]byte(key))
+	return sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+}
+
+// userByAPIKey resolves an API key to its user.
+func userByAPIKey(ctx context.Context, s *state, key string) (database.User, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return database.User{}, errors.New("empty API key")
+	}
+	user, err := s.db.GetUserByAPIKey(ctx, hashAPIKey(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errors.New("invalid API key")
+	}
+	return user, err
+}
+
+// checkKeyOwner refuses to log in as a user who has an API key unless
+// GATOR_API_KEY holds that key.
+func checkKeyOwner(ctx context.Context, s *state, user database.User) error {
+	if !user.ApiKeyHash.Valid {
+		return nil
+	}
+	key, ok := os.LookupEnv(apiKeyEnv)
+	if !ok {
+		return fmt.Errorf("%s has an API key, set %s to it to continue", user.Name, apiKeyEnv)
+	}
+	owner, err := userByAPIKey(ctx, s, key)
+	if err != nil {
+		return err
+	}
+	if owner.ID != user.ID {
+		return fmt.Errorf("%s is not the API key of %s", apiKeyEnv, user.Name)
+	}
+	return nil
+}
+
+func rotateKeyHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	key, hash, err := newAPIKey()
+	if err != nil {
+		return err
+	}
+	err = s.db.SetUserAPIKey(ctx, database.SetUserAPIKeyParams{
+		ApiKeyHash:      hash,
+		ApiKeyCreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt:       sql.NullTime{Time: time.Now(), Valid: true},
+		ID:              user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if s.cfg.CurrentUserName == user.Name {
+		if err = s.cfg.SetUser(user.Name, key); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("new API key for %s (shown only once, the old one no longer works):\n%s\n", user.Name, key)
+	return nil
+}
+
+// revokeKeyHandler removes the user's key. A user without a key is
+// identified by name alone, so until rotatekey issues a new one anybody can
+// log in as them; the command says so rather than leave it implicit.
+func revokeKeyHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	err := s.db.SetUserAPIKey(ctx, database.SetUserAPIKeyParams{
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:        user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if s.cfg.CurrentUserName == user.Name {
+		if err = s.cfg.SetUser(user.Name, ""); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("API key of %s revoked\n", user.Name)
+	fmt.Printf("warning: without a key anyone can log in as %s by name, run rotatekey to protect the account again\n", user.Name)
+	return nil
+}
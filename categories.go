@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+func categoriesHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	categories, err := s.db.GetUserCategories(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if len(categories) == 0 {
+		fmt.Println("no categories yet, create one with addcategory")
+		return nil
+	}
+	for _, category := range categories {
+		fmt.Printf("%s (%d feeds)\n", category.Name, category.FeedCount)
+	}
+	return nil
+}
+
+func addCategoryHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: addcategory <Name>")
+	}
+	category, err := s.db.CreateCategory(ctx, database.CreateCategoryParams{
+		ID:        uuid.New(),
+		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    user.ID,
+		Name:      cmd.args[0],
+	})
+	if err != nil {
+		return fmt.Errorf("can't create category %q: %w", cmd.args[0], err)
+	}
+	fmt.Printf("category %s created\n", category.Name)
+	return nil
+}
+
+func renameCategoryHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return errors.New("usage: renamecategory <Name> <NewName>")
+	}
+	renamed, err := s.db.RenameCategory(ctx, database.RenameCategoryParams{
+		NewName:   cmd.args[1],
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    user.ID,
+		Name:      cmd.args[0],
+	})
+	if err != nil {
+		return err
+	}
+	if renamed == 0 {
+		return fmt.Errorf("no category named %q", cmd.args[0])
+	}
+	fmt.Printf("category %s renamed to %s\n", cmd.args[0], cmd.args[1])
+	return nil
+}
+
+// deleteCategoryHandler removes a category; the feeds in it stay followed.
+func deleteCategoryHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: deletecategory <Name>")
+	}
+	deleted, err := s.db.DeleteCategory(ctx, database.DeleteCategoryParams{
+		UserID: user.ID,
+		Name:   cmd.args[0],
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("no category named %q", cmd.args[0])
+	}
+	fmt.Printf("category %s deleted\n", cmd.args[0])
+	return nil
+}
+
+// categorizeHandler adds a followed feed to one or more categories, keeping
+// the ones it is already in.
+func categorizeHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 2 {
+		return errors.New("usage: categorize <FeedURL> <Category>...")
+	}
+	follow, feed, err := userFollow(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	for _, name := range cmd.args[1:] {
+		if err = addToCategory(ctx, s, user, follow, name); err != nil {
+			return err
+		}
+		fmt.Printf("%s added to %s\n", feed.Name, name)
+	}
+	return nil
+}
+
+func uncategorizeHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) < 2 {
+		return errors.New("usage: uncategorize <FeedURL> <Category>...")
+	}
+	follow, feed, err := userFollow(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	for _, name := range cmd.args[1:] {
+		category, err := userCategory(ctx, s, user, name)
+		if err != nil {
+			return err
+		}
+		removed, err := s.db.RemoveFollowFromCategory(ctx, database.RemoveFollowFromCategoryParams{
+			FeedFollowID: follow.ID,
+			CategoryID:   category.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			return fmt.Errorf("%s is not in %s", feed.Name, name)
+		}
+		fmt.Printf("%s removed from %s\n", feed.Name, name)
+	}
+	return nil
+}
+
+// moveFeedHandler replaces every category of a followed feed with the
+// given one.
+func moveFeedHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return errors.New("usage: movefeed <FeedURL> <Category>")
+	}
+	follow, feed, err := userFollow(ctx, s, user, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	if _, err = userCategory(ctx, s, user, cmd.args[1]); err != nil {
+		return err
+	}
+	if err = s.db.ClearFollowCategories(ctx, follow.ID); err != nil {
+		return err
+	}
+	if err = addToCategory(ctx, s, user, follow, cmd.args[1]); err != nil {
+		return err
+	}
+	fmt.Printf("%s moved to %s\n", feed.Name, cmd.args[1])
+	return nil
+}
+
+func addToCategory(ctx context.Context, s *state, user database.User, follow database.FeedFollow, name string) error {
+	category, err := userCategory(ctx, s, user, name)
+	if err != nil {
+		return err
+	}
+	return s.db.AddFollowToCategory(ctx, database.AddFollowToCategoryParams{
+		FeedFollowID: follow.ID,
+		CategoryID:   category.ID,
+	})
+}
+
+func userCategory(ctx context.Context, s *state, user database.User, name string) (database.Category, error) {
+	category, err := s.db.GetCategoryByName(ctx, database.GetCategoryByNameParams{
+		UserID: user.ID,
+		Name:   name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Category{}, fmt.Errorf("no category named %q, create it with addcategory", name)
+	}
+	return category, err
+}
+
+func userFollow(ctx context.Context, s *state, user database.User, feedURL string) (database.FeedFollow, database.Feed, error) {
+	feed, err := s.db.GetFeedbyurl(ctx, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.FeedFollow{}, database.Feed{}, fmt.Errorf("no feed with URL %s", feedURL)
+	}
+	if err != nil {
+		return database.FeedFollow{}, database.Feed{}, err
+	}
+	follow, err := s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.FeedFollow{}, database.Feed{}, fmt.Errorf("%s doesn't follow %s", user.Name, feed.Name)
+	}
+	return follow, feed, err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// feedCandidate is a URL that was fetched and parsed successfully as a feed.
+type feedCandidate struct {
+	URL  string
+	Feed *RSSFeed
+}
+
+var (
+	linkTagPattern   = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	attributePattern = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/json":      true,
+}
+
+// commonFeedPaths are tried when a page does not advertise any feed.
+var commonFeedPaths = []string{"/feed", "/rss", "/feed.xml", "/rss.xml", "/atom.xml", "/index.xml", "/feed.json"}
+
+// fetchPage downloads a URL with the shared client and returns the body,
+// its content type and the URL it was finally served from.
+func (f *fetcher) fetchPage(ctx context.Context, pageURL string) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	req.Header.Set("User-Agent", "gator")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := f.readBody(resp)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return data, resp.Header.Get("Content-Type"), resp.Request.URL, nil
+}
+
+// discoverFeeds finds the feeds behind pageURL. If pageURL is a feed itself
+// that is the only result; otherwise the feeds advertised with
+// <link rel="alternate"> are returned, or, when there are none, whichever
+// common feed paths exist on the site. Every candidate is fetched and parsed
+// before it is returned.
+func (f *fetcher) discoverFeeds(ctx context.Context, pageURL string) ([]feedCandidate, error) {
+	data, contentType, base, err := f.fetchPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	rss, parseErr := parseFeed(data, contentType)
+	if parseErr == nil {
+		// The requested URL is kept even if it redirected: only a permanent
+		// redirect may change a feed's URL, and scrapeFeed handles that.
+		return []feedCandidate{{URL: pageURL, Feed: rss}}, nil
+	}
+	candidates := f.validateFeeds(ctx, feedLinks(data, base))
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+	if !strings.Contains(contentType, "html") && !bytes.Contains(bytes.ToLower(data[:min(len(data), 1024)]), []byte("<html")) {
+		// Not a web page, so this was meant to be a feed: report why it is
+		// not one instead of guessing other URLs.
+		return nil, parseErr
+	}
+	var guesses []string
+	for _, path := range commonFeedPaths {
+		guesses = append(guesses, base.ResolveReference(&url.URL{Path: path}).String())
+	}
+	return f.validateFeeds(ctx, guesses), nil
+}
+
+func (f *fetcher) validateFeeds(ctx context.Context, urls []string) []feedCandidate {
+	var candidates []feedCandidate
+	seen := map[string]bool{}
+	for _, candidate := range urls {
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		data, contentType, final, err := f.fetchPage(ctx, candidate)
+		if err != nil {
+			continue
+		}
+		rss, err := parseFeed(data, contentType)
+		if err != nil {
+			continue
+		}
+		if final.String() != candidate && seen[final.String()] {
+			continue
+		}
+		seen[final.String()] = true
+		candidates = append(candidates, feedCandidate{URL: candidate, Feed: rss})
+	}
+	return candidates
+}
+
+// feedLinks returns the absolute URLs of the feeds an HTML page advertises.
+func feedLinks(page []byte, base *url.URL) []string {
+	var links []string
+	for _, tag := range linkTagPattern.FindAll(page, -1) {
+		attrs := map[string]string{}
+		for _, match := range attributePattern.FindAllSubmatch(tag, -1) {
+			value := string(match[2]) + string(match[3]) + string(match[4])
+			attrs[strings.ToLower(string(match[1]))] = html.UnescapeString(value)
+		}
+		if !hasToken(attrs["rel"], "alternate") || !feedLinkTypes[strings.ToLower(strings.TrimSpace(attrs["type"]))] {
+			continue
+		}
+		href, err := url.Parse(strings.TrimSpace(attrs["href"]))
+		if err != nil || attrs["href"] == "" {
+			continue
+		}
+		links = append(links, base.ResolveReference(href).String())
+	}
+	return links
+}
+
+func hasToken(list, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseFeed asks the user to pick one of several discovered feeds.
+func chooseFeed(candidates []feedCandidate, in io.Reader, out io.Writer) (feedCandidate, error) {
+	fmt.Fprintln(out, "Several feeds found:")
+	for i, candidate := range candidates {
+		fmt.Fprintf(out, "  %d) %s %s\n", i+1, candidate.Feed.Channel.Title, candidate.URL)
+	}
+	fmt.Fprint(out, "Choose a feed: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return feedCandidate{}, errors.New("no feed chosen, run addfeed again with one of the URLs above")
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return feedCandidate{}, errors.New("invalid choice " + strings.TrimSpace(line))
+	}
+	return candidates[choice-1], nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+type RSSFeed struct {
+	Channel struct {
+		Title           string    `xml:"title"`
+		Link            string    `xml:"link"`
+		Description     string    `xml:"description"`
+		TTL             string    `xml:"ttl"`
+		UpdatePeriod    string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		SkipHours       []string  `xml:"skipHours>hour"`
+		SkipDays        []string  `xml:"skipDays>day"`
+		Item            []RSSItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type RSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+}
+
+// identity returns the value that identifies the item within its feed: the
+// guid when the feed provides one, otherwise the link.
+func (item RSSItem) identity() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	return strings.TrimSpace(item.Link)
+}
+
+type atomFeed struct {
+	Title    atomText    `xml:"title"`
+	Subtitle atomText    `xml:"subtitle"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     atomText   `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// atomText is an Atom text construct. Plain text and html bodies arrive as
+// character data, xhtml bodies as a nested div that we keep as markup.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            json.RawMessage `json:"id"`
+	URL           string          `json:"url"`
+	ExternalURL   string          `json:"external_url"`
+	Title         string          `json:"title"`
+	ContentHTML   string          `json:"content_html"`
+	ContentText   string          `json:"content_text"`
+	Summary       string          `json:"summary"`
+	DatePublished string          `json:"date_published"`
+	DateModified  string          `json:"date_modified"`
+}
+
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+// parseFeed detects the feed format from the content type or the document's
+// root element and decodes it into an RSSFeed, so the rest of the pipeline
+// only deals with one shape.
+func parseFeed(data []byte, contentType string) (*RSSFeed, error) {
+	if isJSONFeed(data, contentType) {
+		return parseJSONFeed(data)
+	}
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "rss":
+		var rss RSSFeed
+		if err := xml.Unmarshal(data, &rss); err != nil {
+			return nil, err
+		}
+		return &rss, nil
+	case "feed":
+		var atom atomFeed
+		if err := xml.Unmarshal(data, &atom); err != nil {
+			return nil, err
+		}
+		return atom.toRSS(), nil
+	default:
+		return nil, errors.New("unsupported feed format: <" + root + ">")
+	}
+}
+
+func isJSONFeed(data []byte, contentType string) bool {
+	if strings.Contains(contentType, "json") {
+		return true
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func parseJSONFeed(data []byte) (*RSSFeed, error) {
+	var feed jsonFeed
+	// encoding/json rejects the byte order mark some servers prepend.
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(feed.Version, jsonFeedVersionPrefix) {
+		return nil, errors.New("not a JSON Feed: missing version")
+	}
+	var rss RSSFeed
+	rss.Channel.Title = feed.Title
+	rss.Channel.Link = feed.HomePageURL
+	rss.Channel.Description = feed.Description
+	for _, item := range feed.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+		date := item.DatePublished
+		if date == "" {
+			date = item.DateModified
+		}
+		description := item.ContentHTML
+		if description == "" {
+			description = item.ContentText
+		}
+		if description == "" {
+			description = item.Summary
+		}
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     date,
+			GUID:        jsonFeedID(item.ID),
+		})
+	}
+	return &rss, nil
+}
+
+// jsonFeedID returns the item id as a string. The spec requires a string,
+// but some generators emit numbers.
+func jsonFeedID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return "", errors.New("empty feed document")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func (a atomFeed) toRSS() *RSSFeed {
+	var rss RSSFeed
+	rss.Channel.Title = a.Title.String()
+	rss.Channel.Link = alternateLink(a.Links)
+	rss.Channel.Description = a.Subtitle.String()
+	for _, entry := range a.Entries {
+		date := entry.Published
+		if date == "" {
+			date = entry.Updated
+		}
+		description := entry.Summary.String()
+		if description == "" {
+			description = entry.Content.String()
+		}
+		rss.Channel.Item = append(rss.Channel.Item, RSSItem{
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     date,
+			GUID:        strings.TrimSpace(entry.ID),
+		})
+	}
+	return &rss
+}
+
+// alternateLink picks the rel="alternate" link, which is also what a link
+// without a rel attribute means, and falls back to the first link.
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
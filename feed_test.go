@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const rssFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Example Blog</title>
+    <link>https://example.com/</link>
+    <description>Posts about examples</description>
+    <ttl>60</ttl>
+    <item>
+      <title>First &amp; foremost</title>
+      <link>https://example.com/first</link>
+      <description>&lt;p&gt;Hello&lt;/p&gt;</description>
+      <pubDate>Mon, 06 May 2024 07:08:09 +0000</pubDate>
+      <guid isPermaLink="false">post-1</guid>
+    </item>
+    <item>
+      <title>No guid</title>
+      <link>https://example.com/second</link>
+    </item>
+  </channel>
+</rss>`
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="html">Atom &amp;lt;Example&amp;gt;</title>
+  <subtitle>An Atom feed</subtitle>
+  <link rel="self" href="https://example.org/feed.atom"/>
+  <link href="https://example.org/"/>
+  <id>urn:uuid:60a76c80-d399-11d9-b93c-0003939e0af6</id>
+  <updated>2024-05-06T07:08:09Z</updated>
+  <entry>
+    <title>Rich entry</title>
+    <link rel="enclosure" href="https://example.org/audio.mp3"/>
+    <link rel="alternate" type="text/html" href="https://example.org/rich"/>
+    <link rel="replies" href="https://example.org/rich#comments"/>
+    <id> tag:example.org,2024:rich </id>
+    <published>2024-05-05T10:00:00+02:00</published>
+    <updated>2024-05-06T07:08:09Z</updated>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Some <b>bold</b> text</p></div></content>
+  </entry>
+  <entry>
+    <title>Updated only</title>
+    <link rel="related" href="https://example.org/related"/>
+    <id>tag:example.org,2024:updated</id>
+    <updated>2024-05-07T00:00:00Z</updated>
+    <summary>  A summary  </summary>
+    <content type="html">&lt;p&gt;Ignored in favour of the summary&lt;/p&gt;</content>
+  </entry>
+</feed>`
+
+func TestParseFeedRSS(t *testing.T) {
+	for _, contentType := range []string{"application/rss+xml", "text/xml; charset=utf-8", ""} {
+		feed, err := parseFeed([]byte(rssFixture), contentType)
+		if err != nil {
+			t.Fatalf("parseFeed(rss, %q) failed: %v", contentType, err)
+		}
+		if feed.Channel.Title != "Example Blog" || feed.Channel.Link != "https://example.com/" || feed.Channel.TTL != "60" {
+			t.Errorf("channel = %+v", feed.Channel)
+		}
+		want := []RSSItem{
+			{Title: "First & foremost", Link: "https://example.com/first", Description: "<p>Hello</p>", PubDate: "Mon, 06 May 2024 07:08:09 +0000", GUID: "post-1"},
+			{Title: "No guid", Link: "https://example.com/second"},
+		}
+		checkItems(t, feed.Channel.Item, want)
+		if got := feed.Channel.Item[1].identity(); got != "https://example.com/second" {
+			t.Errorf("identity without guid = %q, want the link", got)
+		}
+	}
+}
+
+func TestParseFeedAtom(t *testing.T) {
+	feed, err := parseFeed([]byte(atomFixture), "application/atom+xml")
+	if err != nil {
+		t.Fatalf("parseFeed(atom) failed: %v", err)
+	}
+	if feed.Channel.Title != "Atom &lt;Example&gt;" {
+		t.Errorf("title = %q", feed.Channel.Title)
+	}
+	if feed.Channel.Link != "https://example.org/" {
+		t.Errorf("link = %q, want the link without rel", feed.Channel.Link)
+	}
+	if feed.Channel.Description != "An Atom feed" {
+		t.Errorf("description = %q", feed.Channel.Description)
+	}
+	want := []RSSItem{
+		{
+			Title:       "Rich entry",
+			Link:        "https://example.org/rich",
+			Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Some <b>bold</b> text</p></div>`,
+			PubDate:     "2024-05-05T10:00:00+02:00",
+			GUID:        "tag:example.org,2024:rich",
+		},
+		{
+			Title:       "Updated only",
+			Link:        "https://example.org/related",
+			Description: "A summary",
+			PubDate:     "2024-05-07T00:00:00Z",
+			GUID:        "tag:example.org,2024:updated",
+		},
+	}
+	checkItems(t, feed.Channel.Item, want)
+}
+
+const jsonFeedFixture = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON Example",
+  "home_page_url": "https://example.net/",
+  "description": "A JSON Feed",
+  "items": [
+    {
+      "id": 42,
+      "title": "Numeric id, no url",
+      "external_url": "https://elsewhere.example/story",
+      "content_text": "plain text body",
+      "summary": "a summary",
+      "date_modified": "2024-05-06T07:08:09Z"
+    },
+    {
+      "id": "https://example.net/html",
+      "url": "https://example.net/html",
+      "external_url": "https://elsewhere.example/ignored",
+      "title": "HTML body",
+      "content_html": "<p>html body</p>",
+      "content_text": "ignored",
+      "date_published": "2024-05-05T10:00:00+02:00",
+      "date_modified": "2024-05-06T00:00:00Z"
+    },
+    {
+      "id": "summary-only",
+      "summary": "only a summary"
+    }
+  ]
+}`
+
+func TestParseFeedJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+	}{
+		{"content type", jsonFeedFixture, "application/feed+json"},
+		{"sniffed", jsonFeedFixture, "text/plain"},
+		{"sniffed after BOM and whitespace", "\xef\xbb\xbf \n" + jsonFeedFixture, ""},
+	}
+	want := []RSSItem{
+		{Title: "Numeric id, no url", Link: "https://elsewhere.example/story", Description: "plain text body", PubDate: "2024-05-06T07:08:09Z", GUID: "42"},
+		{Title: "HTML body", Link: "https://example.net/html", Description: "<p>html body</p>", PubDate: "2024-05-05T10:00:00+02:00", GUID: "https://example.net/html"},
+		{Description: "only a summary", GUID: "summary-only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(tt.data), tt.contentType)
+			if err != nil {
+				t.Fatalf("parseFeed(json) failed: %v", err)
+			}
+			if feed.Channel.Title != "JSON Example" || feed.Channel.Link != "https://example.net/" || feed.Channel.Description != "A JSON Feed" {
+				t.Errorf("channel = %+v", feed.Channel)
+			}
+			checkItems(t, feed.Channel.Item, want)
+		})
+	}
+}
+
+func TestParseFeedJSONErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"no version":    `{"title": "x", "items": []}`,
+		"other version": `{"version": "1.0", "items": []}`,
+		"invalid json":  `{"version": `,
+	} {
+		if feed, err := parseFeed([]byte(data), "application/json"); err == nil {
+			t.Errorf("%s: parseFeed = %+v, want an error", name, feed)
+		}
+	}
+}
+
+func TestJSONFeedID(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`"abc"`, "abc"},
+		{`"https://example.net/1"`, "https://example.net/1"},
+		{`42`, "42"},
+		{`1.5e3`, "1.5e3"},
+		{` 7 `, "7"},
+		{`""`, ""},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		if got := jsonFeedID(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("jsonFeedID(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseFeedErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+		wantErr     string
+	}{
+		{"html page", "<!DOCTYPE html><html><body>hi</body></html>", "text/html", "unsupported feed format: <html>"},
+		{"unknown root", "<opml version=\"2.0\"></opml>", "", "unsupported feed format: <opml>"},
+		{"empty", "", "", "empty feed document"},
+		{"whitespace", "  \n ", "application/xml", "empty feed document"},
+		{"broken xml", "<rss><channel>", "", "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(tt.data), tt.contentType)
+			if err == nil {
+				t.Fatalf("parseFeed = %+v, want an error", feed)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAtomText(t *testing.T) {
+	tests := []struct {
+		name string
+		text atomText
+		want string
+	}{
+		{"text", atomText{Text: "  plain  ", Inner: "  plain  "}, "plain"},
+		{"html", atomText{Type: "html", Text: "<b>bold</b>", Inner: "&lt;b&gt;bold&lt;/b&gt;"}, "<b>bold</b>"},
+		{"xhtml", atomText{Type: "xhtml", Text: "bold", Inner: " <div><b>bold</b></div> "}, "<div><b>bold</b></div>"},
+		{"empty", atomText{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.text.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlternateLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []atomLink
+		want  string
+	}{
+		{"none", nil, ""},
+		{"no rel", []atomLink{{Rel: "self", Href: "a"}, {Href: "b"}}, "b"},
+		{"alternate", []atomLink{{Rel: "enclosure", Href: "a"}, {Rel: "alternate", Href: "b"}, {Href: "c"}}, "b"},
+		{"first as fallback", []atomLink{{Rel: "related", Href: "a"}, {Rel: "self", Href: "b"}}, "a"},
+	}
+	for _, tt := range tests {
+		if got := alternateLink(tt.links); got != tt.want {
+			t.Errorf("%s: alternateLink = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func checkItems(t *testing.T, got, want []RSSItem) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("item %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+const feedFetchRecordTimeout = 5 * time.Second
+
+// recordFeedFetch adds one scrapeFeed attempt to the feed's fetch history.
+// A fetch that failed because ctx was cancelled, such as one cut off when
+// agg shuts down, is left out: like in aggWorker it is not the feed's
+// failure and would only drag down its success rate.
+func recordFeedFetch(ctx context.Context, s *state, feed database.Feed, started time.Time, result fetchResult, stats scrapeStats, fetchErr error) {
+	if fetchErr != nil && ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), feedFetchRecordTimeout)
+	defer cancel()
+	params := database.RecordFeedFetchParams{
+		ID:           uuid.New(),
+		FeedID:       feed.ID,
+		StartedAt:    started,
+		FinishedAt:   time.Now(),
+		HttpStatus:   sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0},
+		Bytes:        sql.NullInt64{Int64: result.Bytes, Valid: result.Bytes > 0},
+		NewPosts:     int32(stats.New),
+		UpdatedPosts: int32(stats.Updated),
+		NotModified:  result.NotModified,
+	}
+	if result.Feed != nil {
+		params.ItemCount = sql.NullInt32{Int32: int32(len(result.Feed.Channel.Item)), Valid: true}
+	}
+	if fetchErr != nil {
+		params.Error = sql.NullString{String: fetchErr.Error(), Valid: true}
+	}
+	if err := s.db.RecordFeedFetch(ctx, params); err != nil {
+		log.Printf("recording fetch of %s: %v", feed.Url, err)
+	}
+}
+
+func feedStatusHandler(ctx context.Context, s *state, cmd command) error {
+	fs := flag.NewFlagSet("feed-status", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "number of recent fetches to show")
+	since := fs.String("since", "7d", "compute the summary over fetches since this date or age")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || *limit < 1 {
+		return errors.New("usage: feed-status [--limit 20] [--since 7d] <FeedURL>")
+	}
+	from, err := parseTimeBound(*since, time.Now())
+	if err != nil {
+		return err
+	}
+	feed, err := s.db.GetFeedbyurl(ctx, args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no feed with URL %s", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	stats, err := s.db.GetFeedFetchStats(ctx, database.GetFeedFetchStatsParams{
+		FeedID:    feed.ID,
+		StartedAt: from,
+	})
+	if err != nil {
+		return err
+	}
+	fetches, err := s.db.GetFeedFetches(ctx, database.GetFeedFetchesParams{
+		FeedID: feed.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s (%s)\n", feed.Name, feed.Url)
+	switch {
+	case feed.DisabledAt.Valid:
+		fmt.Printf("disabled since %s: %s\n", feed.DisabledAt.Time.UTC().Format(time.DateTime), feed.LastError.String)
+	case feed.ConsecutiveFailures > 0:
+		fmt.Printf("failing, %d in a row: %s\n", feed.ConsecutiveFailures, feed.LastError.String)
+	}
+	if stats.Fetches == 0 {
+		fmt.Printf("no fetches since %s\n", from.Format(time.DateTime))
+	} else {
+		fmt.Printf("since %s: %d fetches, %.1f%% ok, %d not modified, %d new posts, latency avg %v p95 %v\n",
+			from.Format(time.DateTime), stats.Fetches, 100*float64(stats.Successes)/float64(stats.Fetches),
+			stats.NotModified, stats.NewPosts, seconds(stats.AvgSeconds), seconds(stats.P95Seconds))
+	}
+	for _, fetch := range fetches {
+		fmt.Printf("%s %6v %s\n", fetch.StartedAt.UTC().Format(time.DateTime),
+			fetch.FinishedAt.Sub(fetch.StartedAt).Round(time.Millisecond), fetchOutcome(fetch))
+	}
+	return nil
+}
+
+func fetchOutcome(fetch database.FeedFetch) string {
+	status := "---"
+	if fetch.HttpStatus.Valid {
+		status = fmt.Sprint(fetch.HttpStatus.Int32)
+	}
+	switch {
+	case fetch.Error.Valid:
+		return fmt.Sprintf("%s error: %s", status, fetch.Error.String)
+	case fetch.NotModified:
+		return status + " not modified"
+	default:
+		return fmt.Sprintf("%s %d bytes, %d items, %d new, %d updated",
+			status, fetch.Bytes.Int64, fetch.ItemCount.Int32, fetch.NewPosts, fetch.UpdatedPosts)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
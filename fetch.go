@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/yourgfslove/BLOGagregator/internal/config"
+)
+
+const (
+	defaultFetchTimeout   = 30 * time.Second
+	defaultConnectTimeout = 10 * time.Second
+	defaultMaxBodyBytes   = 10 << 20
+	defaultMaxRedirects   = 5
+)
+
+// fetcher downloads feeds with one shared HTTP client, so every worker gets
+// the same timeouts, limits and connection pool.
+type fetcher struct {
+	client       *http.Client
+	maxBodyBytes int64
+}
+
+// fetchResult is the outcome of a conditional feed request. When the
+// publisher answers 304 Not Modified, Feed is nil and NotModified is set.
+// PermanentURL is set when every redirect on the way was a 301 or 308.
+// StatusCode and Bytes, the decoded body size, are filled in as far as the
+// request got, also when fetchFeed fails.
+type fetchResult struct {
+	Feed         *RSSFeed
+	NotModified  bool
+	ETag         string
+	LastModified string
+	PermanentURL string
+	StatusCode   int
+	Bytes        int64
+}
+
+type redirectTrackerKey struct{}
+
+// redirectTracker follows a request through its redirects and remembers the
+// final URL as long as all of them were permanent.
+type redirectTracker struct {
+	temporary bool
+	url       string
+}
+
+// newFetcher builds a fetcher from the fetch settings. A publicOnly fetcher
+// refuses to connect to loopback, private and link-local addresses, also
+// after a redirect or when DNS changes, and ignores proxy settings so the
+// check sees the real destination. It keeps API clients from making the
+// server or agg reach internal services.
+func newFetcher(cfg config.FetchConfig, publicOnly bool) *fetcher {
+	timeout := defaultFetchTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	connectTimeout := defaultConnectTimeout
+	if cfg.ConnectTimeoutSeconds > 0 {
+		connectTimeout = time.Duration(cfg.ConnectTimeoutSeconds) * time.Second
+	}
+	maxBodyBytes := int64(defaultMaxBodyBytes)
+	if cfg.MaxBodyBytes > 0 {
+		maxBodyBytes = cfg.MaxBodyBytes
+	}
+	maxRedirects := defaultMaxRedirects
+	if cfg.MaxRedirects > 0 {
+		maxRedirects = cfg.MaxRedirects
+	}
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	if publicOnly {
+		// Control runs on the resolved address of every connection, so a
+		// host name that resolves to an internal address is caught too.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		}
+		transport.Proxy = nil
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			tracker, ok := req.Context().Value(redirectTrackerKey{}).(*redirectTracker)
+			if !ok || tracker.temporary {
+				return nil
+			}
+			switch req.Response.StatusCode {
+			case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+				tracker.url = req.URL.String()
+			default:
+				tracker.temporary = true
+				tracker.url = ""
+			}
+			return nil
+		},
+	}
+	return &fetcher{client: client, maxBodyBytes: maxBodyBytes}
+}
+
+// checkPublicURL makes sure a URL is http or https and that its host only
+// resolves to public addresses.
+func checkPublicURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q: want http or https", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("URL has no host")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%s resolves to non-public address %s", u.Hostname(), addr.IP)
+		}
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// fetchFeed downloads and parses a feed. A non-empty etag or lastModified
+// from the previous fetch turns the request into a conditional GET.
+func (f *fetcher) fetchFeed(ctx context.Context, feedURL, etag, lastModified string) (fetchResult, error) {
+	result := fetchResult{ETag: etag, LastModified: lastModified}
+	tracker := &redirectTracker{}
+	ctx = context.WithValue(ctx, redirectTrackerKey{}, tracker)
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("User-Agent", "gator")
+	// Setting Accept-Encoding ourselves turns off the transport's transparent
+	// gzip, so decodeBody handles every encoding we advertise.
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	result.PermanentURL = tracker.url
+	if value := resp.Header.Get("ETag"); value != "" {
+		result.ETag = value
+	}
+	if value := resp.Header.Get("Last-Modified"); value != "" {
+		result.LastModified = value
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		return result, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := f.readBody(resp)
+	if err != nil {
+		return result, err
+	}
+	result.Bytes = int64(len(data))
+	result.Feed, err = parseFeed(data, resp.Header.Get("Content-Type"))
+	return result, err
+}
+
+// readBody decompresses the response and reads at most maxBodyBytes of it,
+// counting both the compressed and the decompressed size against the limit.
+func (f *fetcher) readBody(resp *http.Response) ([]byte, error) {
+	if resp.ContentLength > f.maxBodyBytes {
+		return nil, fmt.Errorf("feed is %d bytes, limit is %d", resp.ContentLength, f.maxBodyBytes)
+	}
+	body, err := decodeBody(io.LimitReader(resp.Body, f.maxBodyBytes+1), resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, f.maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.maxBodyBytes {
+		return nil, fmt.Errorf("feed is larger than %d bytes", f.maxBodyBytes)
+	}
+	return data, nil
+}
+
+func decodeBody(body io.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		// "deflate" is supposed to be zlib-wrapped, but plenty of servers send
+		// raw deflate data. Peek at the header to tell them apart.
+		buffered := bufio.NewReader(body)
+		header, err := buffered.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	default:
+		return nil, errors.New("unsupported content encoding " + encoding)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseFlags parses a command's flags, which may appear before, between or
+// after its positional arguments, and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+var timeBoundLayouts = []string{
+	time.RFC3339,
+	time.DateTime,
+	"2006-01-02T15:04",
+	time.DateOnly,
+}
+
+// parseTimeBound reads a date flag: either a point in time such as
+// 2026-01-02 or 2026-01-02T15:04:05Z, or an age such as 90m, 24h or 7d
+// counted back from now. Dates without a zone are in local time. The
+// result is in UTC, like posts.published_at, because a timestamp parameter
+// drops the zone offset.
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n).UTC(), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now.Add(-age).UTC(), nil
+	}
+	for _, layout := range timeBoundLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want a date like 2026-01-02 or an age like 24h or 7d", value)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	// A zone east of UTC catches bounds that keep their local offset, which
+	// a timestamp parameter would silently drop.
+	local := time.Local
+	time.Local = time.FixedZone("CET", 60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2026-01-02", want: time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)},
+		{value: "2026-01-02 15:04:05", want: time.Date(2026, 1, 2, 14, 4, 5, 0, time.UTC)},
+		{value: "2026-01-02T15:04", want: time.Date(2026, 1, 2, 14, 4, 0, 0, time.UTC)},
+		{value: "2026-01-02T15:04:05Z", want: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{value: "2026-01-02T15:04:05+03:00", want: time.Date(2026, 1, 2, 12, 4, 5, 0, time.UTC)},
+		{value: "24h", want: time.Date(2026, 1, 9, 11, 0, 0, 0, time.UTC)},
+		{value: "90m", want: time.Date(2026, 1, 10, 9, 30, 0, 0, time.UTC)},
+		{value: " 7d ", want: time.Date(2026, 1, 3, 11, 0, 0, 0, time.UTC)},
+		{value: "0d", want: time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{value: "-24h", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "yesterday", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.value, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTimeBound(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTimeBound(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("parseTimeBound(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
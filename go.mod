@@ -3,6 +3,7 @@ module github.com/yourgfslove/BLOGagregator
 go 1.24.2
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/andybalholm/brotli v1.2.0
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
 )
@@ -25,8 +25,11 @@ func Read() (Config, error) {
 	return config, nil
 }
 
-func (config *Config) SetUser(username string) error {
+// SetUser logs username in. apiKey is the user's API key, or empty for a
+// user without one.
+func (config *Config) SetUser(username, apiKey string) error {
 	config.CurrentUserName = username
+	config.APIKey = apiKey
 	err := write(*config)
 	if err != nil {
 		return err
@@ -48,11 +51,16 @@ func write(cfg Config) error {
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(cfgpath)
+	// The file holds the logged-in user's API key, so only its owner may
+	// read it.
+	file, err := os.OpenFile(cfgpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	if err = file.Chmod(0o600); err != nil {
+		return err
+	}
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
 	err = encoder.Encode(cfg)
@@ -1,6 +1,22 @@
 package config
 
 type Config struct {
-	DbURL           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DbURL           string      `json:"db_url"`
+	CurrentUserName string      `json:"current_user_name"`
+	APIKey          string      `json:"api_key,omitempty"`
+	MaxFeedFailures int         `json:"max_feed_failures,omitempty"`
+	Fetch           FetchConfig `json:"fetch,omitzero"`
+}
+
+// FetchConfig tunes the HTTP client used to download feeds. Zero values
+// fall back to the defaults in the main package.
+type FetchConfig struct {
+	TimeoutSeconds        int   `json:"timeout_seconds,omitempty"`
+	ConnectTimeoutSeconds int   `json:"connect_timeout_seconds,omitempty"`
+	MaxBodyBytes          int64 `json:"max_body_bytes,omitempty"`
+	MaxRedirects          int   `json:"max_redirects,omitempty"`
+	// AllowPrivateAddresses lets agg and addfeed fetch from loopback,
+	// private and link-local addresses, for feeds served on the local
+	// network. The API server never does.
+	AllowPrivateAddresses bool `json:"allow_private_addresses,omitempty"`
 }
@@ -72,7 +72,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 	return i, err
 }
 
-const deleteFollow = `-- name: DeleteFollow :exec
+const deleteFollow = `-- name: DeleteFollow :execrows
 DELETE FROM feed_follow
 WHERE user_id = $1 AND feed_id = $2
 `
@@ -82,13 +82,40 @@ type DeleteFollowParams struct {
 	FeedID uuid.UUID
 }
 
-func (q *Queries) DeleteFollow(ctx context.Context, arg DeleteFollowParams) error {
-	_, err := q.db.ExecContext(ctx, deleteFollow, arg.UserID, arg.FeedID)
-	return err
+func (q *Queries) DeleteFollow(ctx context.Context, arg DeleteFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFollow, arg.UserID, arg.FeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id
+FROM feed_follow
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+	)
+	return i, err
 }
 
 const getUsersFollowList = `-- name: GetUsersFollowList :many
-SELECT feeds.name, users.name
+SELECT feeds.name, users.name, feeds.url, feeds.id AS feed_id, feeds.site_url
 FROM feed_follow
 INNER JOIN feeds ON feeds.id = feed_follow.feed_id
 INNER JOIN users ON users.id = feed_follow.user_id
@@ -96,8 +123,11 @@ WHERE feed_follow.user_id = $1
 `
 
 type GetUsersFollowListRow struct {
-	Name   string
-	Name_2 string
+	Name    string
+	Name_2  string
+	Url     string
+	FeedID  uuid.UUID
+	SiteUrl sql.NullString
 }
 
 func (q *Queries) GetUsersFollowList(ctx context.Context, userID uuid.UUID) ([]GetUsersFollowListRow, error) {
@@ -109,7 +139,13 @@ func (q *Queries) GetUsersFollowList(ctx context.Context, userID uuid.UUID) ([]G
 	var items []GetUsersFollowListRow
 	for rows.Next() {
 		var i GetUsersFollowListRow
-		if err := rows.Scan(&i.Name, &i.Name_2); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.Name_2,
+			&i.Url,
+			&i.FeedID,
+			&i.SiteUrl,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
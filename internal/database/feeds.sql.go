@@ -8,30 +8,81 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimNextFeedToFetch = `-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET next_fetch_at = $1
+WHERE id = (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= $2)
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_error, consecutive_failures, last_error_at, next_fetch_at, disabled_at, etag, last_modified, fetch_interval_seconds, hinted_interval_seconds, description, site_url
+`
+
+type ClaimNextFeedToFetchParams struct {
+	LeaseUntil sql.NullTime
+	Now        sql.NullTime
+}
+
+func (q *Queries) ClaimNextFeedToFetch(ctx context.Context, arg ClaimNextFeedToFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimNextFeedToFetch, arg.LeaseUntil, arg.Now)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+		&i.Description,
+		&i.SiteUrl,
+	)
+	return i, err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, description, site_url)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, last_error, consecutive_failures, last_error_at, next_fetch_at, disabled_at, etag, last_modified, fetch_interval_seconds, hinted_interval_seconds, description, site_url
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID          uuid.UUID
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+	Name        string
+	Url         string
+	UserID      uuid.UUID
+	Description sql.NullString
+	SiteUrl     sql.NullString
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -42,6 +93,8 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.Description,
+		arg.SiteUrl,
 	)
 	var i Feed
 	err := row.Scan(
@@ -52,21 +105,59 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
+const enableFeed = `-- name: EnableFeed :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL
+WHERE id = $2
+`
+
+type EnableFeedParams struct {
+	UpdatedAt sql.NullTime
+	ID        uuid.UUID
+}
+
+func (q *Queries) EnableFeed(ctx context.Context, arg EnableFeedParams) error {
+	_, err := q.db.ExecContext(ctx, enableFeed, arg.UpdatedAt, arg.ID)
+	return err
+}
+
 const feeds = `-- name: Feeds :many
-SELECT feeds.name, feeds.url, users.name
+SELECT feeds.name, feeds.url, users.name, feeds.last_fetched_at, feeds.consecutive_failures, feeds.last_error, feeds.next_fetch_at, feeds.disabled_at, feeds.fetch_interval_seconds, feeds.hinted_interval_seconds
 FROM feeds
 INNER JOIN users
 ON users.id = feeds.user_id
 `
 
 type FeedsRow struct {
-	Name   string
-	Url    string
-	Name_2 string
+	Name                  string
+	Url                   string
+	Name_2                string
+	LastFetchedAt         sql.NullTime
+	ConsecutiveFailures   int32
+	LastError             sql.NullString
+	NextFetchAt           sql.NullTime
+	DisabledAt            sql.NullTime
+	FetchIntervalSeconds  sql.NullInt32
+	HintedIntervalSeconds sql.NullInt32
 }
 
 func (q *Queries) Feeds(ctx context.Context) ([]FeedsRow, error) {
@@ -78,7 +169,18 @@ func (q *Queries) Feeds(ctx context.Context) ([]FeedsRow, error) {
 	var items []FeedsRow
 	for rows.Next() {
 		var i FeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.Name_2); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.Name_2,
+			&i.LastFetchedAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.FetchIntervalSeconds,
+			&i.HintedIntervalSeconds,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -93,7 +195,7 @@ func (q *Queries) Feeds(ctx context.Context) ([]FeedsRow, error) {
 }
 
 const getFeedbyurl = `-- name: GetFeedbyurl :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, last_error, consecutive_failures, last_error_at, next_fetch_at, disabled_at, etag, last_modified, fetch_interval_seconds, hinted_interval_seconds, description, site_url
 FROM feeds
 WHERE url = $1
 `
@@ -109,31 +211,32 @@ func (q *Queries) GetFeedbyurl(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.LastErrorAt,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+		&i.Description,
+		&i.SiteUrl,
 	)
 	return i, err
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+const getSchedulerLag = `-- name: GetSchedulerLag :one
+SELECT COALESCE(EXTRACT(EPOCH FROM $1::timestamp - MIN(last_fetched_at)), 0)::float8 AS lag_seconds
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+WHERE disabled_at IS NULL
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
-	var i Feed
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Name,
-		&i.Url,
-		&i.UserID,
-		&i.LastFetchedAt,
-	)
-	return i, err
+func (q *Queries) GetSchedulerLag(ctx context.Context, now time.Time) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getSchedulerLag, now)
+	var lag_seconds float64
+	err := row.Scan(&lag_seconds)
+	return lag_seconds, err
 }
 
 const markFeedFetched = `-- name: MarkFeedFetched :exec
@@ -141,17 +244,104 @@ const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET
     updated_at =      $1,
-    last_fetched_at = $2
-WHERE id = $3
+    last_fetched_at = $2,
+    last_error = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = $3,
+    etag = $4,
+    last_modified = $5,
+    hinted_interval_seconds = $6
+WHERE id = $7
 `
 
 type MarkFeedFetchedParams struct {
-	UpdatedAt     sql.NullTime
-	LastFetchedAt sql.NullTime
-	ID            uuid.UUID
+	UpdatedAt             sql.NullTime
+	LastFetchedAt         sql.NullTime
+	NextFetchAt           sql.NullTime
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	HintedIntervalSeconds sql.NullInt32
+	ID                    uuid.UUID
 }
 
 func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.UpdatedAt, arg.LastFetchedAt, arg.ID)
+	_, err := q.db.ExecContext(ctx, markFeedFetched,
+		arg.UpdatedAt,
+		arg.LastFetchedAt,
+		arg.NextFetchAt,
+		arg.Etag,
+		arg.LastModified,
+		arg.HintedIntervalSeconds,
+		arg.ID,
+	)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    last_error = $2,
+    last_error_at = $1,
+    consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = $3,
+    disabled_at = $4
+WHERE id = $5
+`
+
+type RecordFeedFailureParams struct {
+	UpdatedAt   sql.NullTime
+	LastError   sql.NullString
+	NextFetchAt sql.NullTime
+	DisabledAt  sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFailure,
+		arg.UpdatedAt,
+		arg.LastError,
+		arg.NextFetchAt,
+		arg.DisabledAt,
+		arg.ID,
+	)
+	return err
+}
+
+const setFeedInterval = `-- name: SetFeedInterval :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    fetch_interval_seconds = $2
+WHERE id = $3
+`
+
+type SetFeedIntervalParams struct {
+	UpdatedAt            sql.NullTime
+	FetchIntervalSeconds sql.NullInt32
+	ID                   uuid.UUID
+}
+
+func (q *Queries) SetFeedInterval(ctx context.Context, arg SetFeedIntervalParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedInterval, arg.UpdatedAt, arg.FetchIntervalSeconds, arg.ID)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    url = $2
+WHERE id = $3
+`
+
+type UpdateFeedURLParams struct {
+	UpdatedAt sql.NullTime
+	Url       string
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.UpdatedAt, arg.Url, arg.ID)
 	return err
 }
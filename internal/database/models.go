@@ -6,18 +6,52 @@ package database
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+type Category struct {
+	ID        uuid.UUID
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
+	UserID    uuid.UUID
+	Name      string
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     sql.NullTime
-	UpdatedAt     sql.NullTime
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                    uuid.UUID
+	CreatedAt             sql.NullTime
+	UpdatedAt             sql.NullTime
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	LastError             sql.NullString
+	ConsecutiveFailures   int32
+	LastErrorAt           sql.NullTime
+	NextFetchAt           sql.NullTime
+	DisabledAt            sql.NullTime
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	FetchIntervalSeconds  sql.NullInt32
+	HintedIntervalSeconds sql.NullInt32
+	Description           sql.NullString
+	SiteUrl               sql.NullString
+}
+
+type FeedFetch struct {
+	ID           uuid.UUID
+	FeedID       uuid.UUID
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	HttpStatus   sql.NullInt32
+	Bytes        sql.NullInt64
+	ItemCount    sql.NullInt32
+	NewPosts     int32
+	UpdatedPosts int32
+	NotModified  bool
+	Error        sql.NullString
 }
 
 type FeedFollow struct {
@@ -28,20 +62,38 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type FeedFollowCategory struct {
+	FeedFollowID uuid.UUID
+	CategoryID   uuid.UUID
+}
+
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   sql.NullTime
-	UpdatedAt   sql.NullTime
-	PublishedAt sql.NullTime
-	Title       string
-	Url         string
-	Description sql.NullString
-	FeedID      uuid.UUID
+	ID                   uuid.UUID
+	CreatedAt            sql.NullTime
+	UpdatedAt            sql.NullTime
+	PublishedAt          sql.NullTime
+	Title                string
+	Url                  string
+	Description          sql.NullString
+	FeedID               uuid.UUID
+	PublishedAtEstimated bool
+	Guid                 string
+	SearchVector         interface{}
+}
+
+type PostState struct {
+	UserID     uuid.UUID
+	PostID     uuid.UUID
+	ReadAt     sql.NullTime
+	StarredAt  sql.NullTime
+	ArchivedAt sql.NullTime
 }
 
 type User struct {
-	ID        uuid.UUID
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
-	Name      string
+	ID              uuid.UUID
+	CreatedAt       sql.NullTime
+	UpdatedAt       sql.NullTime
+	Name            string
+	ApiKeyHash      sql.NullString
+	ApiKeyCreatedAt sql.NullTime
 }
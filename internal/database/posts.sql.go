@@ -8,81 +8,209 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :exec
-INSERT INTO posts (id, created_at, updated_at, published_at, title, url, description, feed_id)
-VALUES (
-        $1,
-        $2,
-        $3,
-        $4,
-        $5,
-        $6,
-        $7,
-        $8
-)
+const adoptLegacyPostGuids = `-- name: AdoptLegacyPostGuids :execrows
+UPDATE posts
+SET guid = item.guid
+FROM unnest($1::text[], $2::text[]) AS item(guid, url)
+WHERE posts.feed_id = $3
+  AND posts.guid = posts.url
+  AND posts.url = item.url
+  AND item.guid <> item.url
+  AND NOT EXISTS (
+      SELECT 1
+      FROM posts AS existing
+      WHERE existing.feed_id = posts.feed_id
+        AND existing.guid = item.guid
+  )
 `
 
-type CreatePostParams struct {
+type AdoptLegacyPostGuidsParams struct {
+	Guids  []string
+	Urls   []string
+	FeedID uuid.UUID
+}
+
+func (q *Queries) AdoptLegacyPostGuids(ctx context.Context, arg AdoptLegacyPostGuidsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, adoptLegacyPostGuids, pq.Array(arg.Guids), pq.Array(arg.Urls), arg.FeedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFilteredPosts = `-- name: GetFilteredPosts :many
+SELECT posts.id, posts.published_at, posts.title, posts.url, posts.description, feeds.name AS feed_name, posts.feed_id, post_states.read_at, post_states.starred_at, post_states.archived_at
+FROM posts
+JOIN feed_follow ON posts.feed_id = feed_follow.feed_id
+JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follow.user_id
+WHERE feed_follow.user_id = $1
+  AND (NOT $2::boolean OR post_states.read_at IS NULL)
+  AND (NOT $3::boolean OR post_states.starred_at IS NOT NULL)
+  AND ($4::boolean OR post_states.archived_at IS NULL)
+  AND ($5::text IS NULL OR EXISTS (
+        SELECT 1
+        FROM feed_follow_categories
+        INNER JOIN categories ON categories.id = feed_follow_categories.category_id
+        WHERE feed_follow_categories.feed_follow_id = feed_follow.id
+          AND categories.name = $5
+  ))
+  AND ($6::text IS NULL OR feeds.url = $6 OR feeds.name = $6)
+  AND ($7::timestamp IS NULL OR posts.published_at >= $7)
+  AND ($8::timestamp IS NULL OR posts.published_at < $8)
+  AND ($9::text IS NULL OR posts.title ILIKE '%' || $9 || '%')
+  AND ($10::timestamp IS NULL OR CASE WHEN $11::boolean
+        THEN (COALESCE(posts.published_at, 'epoch'::timestamp), posts.id) > ($10, $12::uuid)
+        ELSE (COALESCE(posts.published_at, 'epoch'::timestamp), posts.id) < ($10, $12::uuid)
+  END)
+ORDER BY
+    CASE WHEN $11::boolean THEN COALESCE(posts.published_at, 'epoch'::timestamp) END ASC,
+    CASE WHEN $11::boolean THEN posts.id END ASC,
+    COALESCE(posts.published_at, 'epoch'::timestamp) DESC,
+    posts.id DESC
+LIMIT $13
+OFFSET $14
+`
+
+type GetFilteredPostsParams struct {
+	UserID            uuid.UUID
+	UnreadOnly        bool
+	StarredOnly       bool
+	IncludeArchived   bool
+	Category          sql.NullString
+	Feed              sql.NullString
+	Since             sql.NullTime
+	Before            sql.NullTime
+	Title             sql.NullString
+	CursorPublishedAt sql.NullTime
+	OldestFirst       bool
+	CursorID          uuid.NullUUID
+	Limit             int32
+	Offset            int32
+}
+
+type GetFilteredPostsRow struct {
 	ID          uuid.UUID
-	CreatedAt   sql.NullTime
-	UpdatedAt   sql.NullTime
 	PublishedAt sql.NullTime
 	Title       string
 	Url         string
 	Description sql.NullString
+	FeedName    string
 	FeedID      uuid.UUID
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	ArchivedAt  sql.NullTime
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) error {
-	_, err := q.db.ExecContext(ctx, createPost,
-		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.PublishedAt,
+func (q *Queries) GetFilteredPosts(ctx context.Context, arg GetFilteredPostsParams) ([]GetFilteredPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFilteredPosts,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.IncludeArchived,
+		arg.Category,
+		arg.Feed,
+		arg.Since,
+		arg.Before,
 		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.FeedID,
+		arg.CursorPublishedAt,
+		arg.OldestFirst,
+		arg.CursorID,
+		arg.Limit,
+		arg.Offset,
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFilteredPostsRow
+	for rows.Next() {
+		var i GetFilteredPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PublishedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.FeedName,
+			&i.FeedID,
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getPosts = `-- name: GetPosts :many
-SELECT posts.id, posts.created_at, posts.updated_at, published_at, title, url, description, posts.feed_id, feed_follow.id, feed_follow.created_at, feed_follow.updated_at, user_id, feed_follow.feed_id
+SELECT posts.id, posts.published_at, posts.title, posts.url, posts.description, feeds.name AS feed_name, post_states.read_at, post_states.starred_at, post_states.archived_at
 FROM posts
 JOIN feed_follow ON posts.feed_id = feed_follow.feed_id
+JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follow.user_id
 WHERE feed_follow.user_id = $1
+  AND (NOT $2::boolean OR post_states.read_at IS NULL)
+  AND (NOT $3::boolean OR post_states.starred_at IS NOT NULL)
+  AND ($4::boolean OR post_states.archived_at IS NULL)
+  AND ($5::text IS NULL OR EXISTS (
+        SELECT 1
+        FROM feed_follow_categories
+        INNER JOIN categories ON categories.id = feed_follow_categories.category_id
+        WHERE feed_follow_categories.feed_follow_id = feed_follow.id
+          AND categories.name = $5
+  ))
 ORDER BY posts.published_at DESC
-LIMIT $2
+LIMIT $6
+OFFSET $7
 `
 
 type GetPostsParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID          uuid.UUID
+	UnreadOnly      bool
+	StarredOnly     bool
+	IncludeArchived bool
+	Category        sql.NullString
+	Limit           int32
+	Offset          int32
 }
 
 type GetPostsRow struct {
 	ID          uuid.UUID
-	CreatedAt   sql.NullTime
-	UpdatedAt   sql.NullTime
 	PublishedAt sql.NullTime
 	Title       string
 	Url         string
 	Description sql.NullString
-	FeedID      uuid.UUID
-	ID_2        uuid.UUID
-	CreatedAt_2 sql.NullTime
-	UpdatedAt_2 sql.NullTime
-	UserID      uuid.UUID
-	FeedID_2    uuid.UUID
+	FeedName    string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	ArchivedAt  sql.NullTime
 }
 
 func (q *Queries) GetPosts(ctx context.Context, arg GetPostsParams) ([]GetPostsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPosts, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPosts,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.IncludeArchived,
+		arg.Category,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -92,18 +220,71 @@ func (q *Queries) GetPosts(ctx context.Context, arg GetPostsParams) ([]GetPostsR
 		var i GetPostsRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
 			&i.PublishedAt,
 			&i.Title,
 			&i.Url,
 			&i.Description,
-			&i.FeedID,
-			&i.ID_2,
-			&i.CreatedAt_2,
-			&i.UpdatedAt_2,
-			&i.UserID,
-			&i.FeedID_2,
+			&i.FeedName,
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT posts.id, posts.published_at, posts.title, posts.url, feeds.name AS feed_name, ts_rank_cd(posts.search_vector, search_query)::real AS rank
+FROM posts
+JOIN feed_follow ON feed_follow.feed_id = posts.feed_id
+JOIN feeds ON feeds.id = posts.feed_id
+CROSS JOIN to_tsquery('english', $1) AS search_query
+WHERE feed_follow.user_id = $2
+  AND posts.search_vector @@ search_query
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT $3
+`
+
+type SearchPostsParams struct {
+	Query  string
+	UserID uuid.UUID
+	Limit  int32
+}
+
+type SearchPostsRow struct {
+	ID          uuid.UUID
+	PublishedAt sql.NullTime
+	Title       string
+	Url         string
+	FeedName    string
+	Rank        float32
+}
+
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPosts, arg.Query, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PublishedAt,
+			&i.Title,
+			&i.Url,
+			&i.FeedName,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -117,3 +298,75 @@ func (q *Queries) GetPosts(ctx context.Context, arg GetPostsParams) ([]GetPostsR
 	}
 	return items, nil
 }
+
+const upsertPosts = `-- name: UpsertPosts :many
+INSERT INTO posts (id, created_at, updated_at, published_at, title, url, description, feed_id, published_at_estimated, guid)
+SELECT item.id, $1::timestamp, $1::timestamp, item.published_at, item.title, item.url, item.description, $2::uuid, item.published_at_estimated, item.guid
+FROM unnest(
+        $3::uuid[],
+        $4::timestamp[],
+        $5::text[],
+        $6::text[],
+        $7::text[],
+        $8::boolean[],
+        $9::text[]
+) AS item(id, published_at, title, url, description, published_at_estimated, guid)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET
+    updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = CASE WHEN EXCLUDED.published_at_estimated THEN posts.published_at ELSE EXCLUDED.published_at END,
+    published_at_estimated = posts.published_at_estimated AND EXCLUDED.published_at_estimated
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+   OR posts.url IS DISTINCT FROM EXCLUDED.url
+   OR posts.description IS DISTINCT FROM EXCLUDED.description
+   OR (NOT EXCLUDED.published_at_estimated AND posts.published_at IS DISTINCT FROM EXCLUDED.published_at)
+RETURNING (xmax = 0)::boolean AS inserted
+`
+
+type UpsertPostsParams struct {
+	Now                  time.Time
+	FeedID               uuid.UUID
+	Ids                  []uuid.UUID
+	PublishedAts         []time.Time
+	Titles               []string
+	Urls                 []string
+	Descriptions         []string
+	PublishedAtEstimated []bool
+	Guids                []string
+}
+
+func (q *Queries) UpsertPosts(ctx context.Context, arg UpsertPostsParams) ([]bool, error) {
+	rows, err := q.db.QueryContext(ctx, upsertPosts,
+		arg.Now,
+		arg.FeedID,
+		pq.Array(arg.Ids),
+		pq.Array(arg.PublishedAts),
+		pq.Array(arg.Titles),
+		pq.Array(arg.Urls),
+		pq.Array(arg.Descriptions),
+		pq.Array(arg.PublishedAtEstimated),
+		pq.Array(arg.Guids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []bool
+	for rows.Next() {
+		var inserted bool
+		if err := rows.Scan(&inserted); err != nil {
+			return nil, err
+		}
+		items = append(items, inserted)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
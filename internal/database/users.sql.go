@@ -13,21 +13,25 @@ import (
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, api_key_hash, api_key_created_at)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, api_key_hash, api_key_created_at
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
-	Name      string
+	ID              uuid.UUID
+	CreatedAt       sql.NullTime
+	UpdatedAt       sql.NullTime
+	Name            string
+	ApiKeyHash      sql.NullString
+	ApiKeyCreatedAt sql.NullTime
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -36,6 +40,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.ApiKeyHash,
+		arg.ApiKeyCreatedAt,
 	)
 	var i User
 	err := row.Scan(
@@ -43,12 +49,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyCreatedAt,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_created_at
 FROM users
 WHERE name = $1
 `
@@ -61,12 +69,34 @@ func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyCreatedAt,
+	)
+	return i, err
+}
+
+const getUserByAPIKey = `-- name: GetUserByAPIKey :one
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_created_at
+FROM users
+WHERE api_key_hash = $1
+`
+
+func (q *Queries) GetUserByAPIKey(ctx context.Context, apiKeyHash sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByAPIKey, apiKeyHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKeyHash,
+		&i.ApiKeyCreatedAt,
 	)
 	return i, err
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, api_key_hash, api_key_created_at
 FROM users
 `
 
@@ -84,6 +114,8 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Name,
+			&i.ApiKeyHash,
+			&i.ApiKeyCreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -106,3 +138,29 @@ func (q *Queries) Reset(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, reset)
 	return err
 }
+
+const setUserAPIKey = `-- name: SetUserAPIKey :exec
+UPDATE users
+SET
+    api_key_hash = $1,
+    api_key_created_at = $2,
+    updated_at = $3
+WHERE id = $4
+`
+
+type SetUserAPIKeyParams struct {
+	ApiKeyHash      sql.NullString
+	ApiKeyCreatedAt sql.NullTime
+	UpdatedAt       sql.NullTime
+	ID              uuid.UUID
+}
+
+func (q *Queries) SetUserAPIKey(ctx context.Context, arg SetUserAPIKeyParams) error {
+	_, err := q.db.ExecContext(ctx, setUserAPIKey,
+		arg.ApiKeyHash,
+		arg.ApiKeyCreatedAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
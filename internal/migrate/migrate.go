@@ -0,0 +1,295 @@
+// Package migrate applies goose-style SQL migrations. It keeps track of
+// applied versions in goose's own goose_db_version table, so a database
+// migrated with the goose CLI and one migrated by the binary are
+// interchangeable.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const versionTable = "goose_db_version"
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads every *.sql migration at the root of fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	m := &Migrator{db: db}
+	seen := map[int64]string{}
+	for _, file := range files {
+		version, err := fileVersion(file)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		up, down := parseMigration(string(data))
+		m.migrations = append(m.migrations, Migration{Version: version, Name: file, Up: up, Down: down})
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return m, nil
+}
+
+// Latest returns the version the embedded migrations bring the schema to.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the highest applied version, 0 for an empty database.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Pending returns the migrations that have not been applied yet. It only
+// reads, so it is safe to call on every start.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	if version == 0 {
+		return Migration{}, errors.New("no migrations to roll back")
+	}
+	migration, ok := m.find(version)
+	if !ok {
+		return Migration{}, fmt.Errorf("version %d is applied but unknown to this binary", version)
+	}
+	return migration, m.run(ctx, migration, false)
+}
+
+// To migrates up or down until the schema is at version and returns the
+// migrations applied or rolled back, in the order they ran.
+func (m *Migrator) To(ctx context.Context, version int64) ([]Migration, error) {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return nil, fmt.Errorf("unknown version %d", version)
+	}
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := m.run(ctx, migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+		if err := m.run(ctx, migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// run applies or rolls back one migration together with its bookkeeping
+// row, so a failing migration leaves no trace.
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	statements := migration.Down
+	if up {
+		statements = migration.Up
+	}
+	if strings.TrimSpace(statements) != "" {
+		if _, err := tx.ExecContext(ctx, statements); err != nil {
+			return fmt.Errorf("%s: %w", migration.Name, err)
+		}
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES ($1, true)", migration.Version)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+versionTable+" WHERE version_id = $1", migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// applied maps every applied version to the time it was applied. Like
+// goose, the latest row of a version decides whether it is applied.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	var table sql.NullString
+	err := m.db.QueryRowContext(ctx, "SELECT to_regclass($1)::text", versionTable).Scan(&table)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int64]time.Time{}
+	if !table.Valid {
+		return applied, nil
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM "+versionTable+" ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	seen := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var at sql.NullTime
+		if err := rows.Scan(&version, &isApplied, &at); err != nil {
+			return nil, err
+		}
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		if isApplied && version != 0 {
+			applied[version] = at.Time
+		}
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) ensureVersionTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (
+    id SERIAL PRIMARY KEY,
+    version_id BIGINT NOT NULL,
+    is_applied BOOLEAN NOT NULL,
+    tstamp TIMESTAMP DEFAULT now()
+)`)
+	return err
+}
+
+func fileVersion(file string) (int64, error) {
+	prefix, _, ok := strings.Cut(path.Base(file), "_")
+	if !ok {
+		return 0, fmt.Errorf("migration %s: name must start with <version>_", file)
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("migration %s: invalid version %q", file, prefix)
+	}
+	return version, nil
+}
+
+// parseMigration splits a goose file into its Up and Down sections. Each
+// section runs as one multi-statement Exec, so the StatementBegin and
+// StatementEnd markers need no special handling.
+func parseMigration(data string) (up, down string) {
+	var current *strings.Builder
+	var upSQL, downSQL strings.Builder
+	for _, line := range strings.Split(data, "\n") {
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				current = &upSQL
+			case "Down":
+				current = &downSQL
+			}
+			continue
+		}
+		if current != nil {
+			current.WriteString(line)
+			current.WriteString("\n")
+		}
+	}
+	return upSQL.String(), downSQL.String()
+}
@@ -3,43 +3,38 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/yourgfslove/BLOGagregator/internal/config"
 	"github.com/yourgfslove/BLOGagregator/internal/database"
 	"html"
-	"io"
 	"log"
-	"net/http"
+	"math"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
-type RSSFeed struct {
-	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
-	} `xml:"channel"`
-}
-
-const TimeLayout = "Mon, 02 Jan 2006 15:04:05 -0700"
+const (
+	defaultMaxFeedFailures = 10
+	maxFeedBackoff         = 24 * time.Hour
+	defaultShutdownGrace   = 30 * time.Second
+	postBatchSize          = 500
+)
 
-type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-}
 type state struct {
-	db  *database.Queries
-	cfg *config.Config
+	conn    *sql.DB
+	db      *database.Queries
+	cfg     *config.Config
+	fetcher *fetcher
+	metrics *metrics
 }
 
 type command struct {
@@ -48,19 +43,19 @@ type command struct {
 }
 
 type commands struct {
-	commandMap map[string]func(*state, command) error
+	commandMap map[string]func(context.Context, *state, command) error
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
+func (c *commands) register(name string, f func(context.Context, *state, command) error) {
 	c.commandMap[name] = f
 }
 
-func (c *commands) run(s *state, cmd command) error {
+func (c *commands) run(ctx context.Context, s *state, cmd command) error {
 	handler, ok := c.commandMap[cmd.name]
 	if !ok {
 		return errors.New("command not found")
 	}
-	return handler(s, cmd)
+	return handler(ctx, s, cmd)
 }
 
 var s state
@@ -75,10 +70,15 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	dbquery := database.New(dbconn)
+	s.metrics = newMetrics()
+	dbquery := database.New(s.metrics.instrumentDB(dbconn))
 	s.cfg = &cfg
+	s.conn = dbconn
 	s.db = dbquery
-	cmds = commands{make(map[string]func(*state, command) error)}
+	// Feeds added through the API are fetched by agg as well, so unless the
+	// config opts out, no fetch may reach internal services.
+	s.fetcher = newFetcher(cfg.Fetch, !cfg.Fetch.AllowPrivateAddresses)
+	cmds = commands{make(map[string]func(context.Context, *state, command) error)}
 	cmds.register("login", loginHandler)
 	cmds.register("register", registerHandler)
 	cmds.register("reset", middlewareLoggedIn(resetHandler))
@@ -86,29 +86,74 @@ func main() {
 	cmds.register("agg", aggHandler)
 	cmds.register("addfeed", middlewareLoggedIn(addFeedHandler))
 	cmds.register("feeds", feedsHandler)
+	cmds.register("enablefeed", middlewareLoggedIn(enableFeedHandler))
+	cmds.register("setinterval", middlewareLoggedIn(setIntervalHandler))
 	cmds.register("follow", middlewareLoggedIn(followHandler))
 	cmds.register("following", middlewareLoggedIn(followingHandler))
 	cmds.register("unfollow", middlewareLoggedIn(unfollowHandler))
 	cmds.register("getposts", middlewareLoggedIn(GetPostshandler))
+	cmds.register("markread", middlewareLoggedIn(markReadHandler))
+	cmds.register("markunread", middlewareLoggedIn(markUnreadHandler))
+	cmds.register("star", middlewareLoggedIn(starHandler))
+	cmds.register("unstar", middlewareLoggedIn(unstarHandler))
+	cmds.register("archive", middlewareLoggedIn(archiveHandler))
+	cmds.register("unarchive", middlewareLoggedIn(unarchiveHandler))
+	cmds.register("markfeedread", middlewareLoggedIn(markFeedReadHandler))
+	cmds.register("search", middlewareLoggedIn(searchHandler))
+	cmds.register("import-opml", middlewareLoggedIn(importOPMLHandler))
+	cmds.register("export-opml", middlewareLoggedIn(exportOPMLHandler))
+	cmds.register("serve", serveHandler)
+	cmds.register("rotatekey", middlewareLoggedIn(rotateKeyHandler))
+	cmds.register("revokekey", middlewareLoggedIn(revokeKeyHandler))
+	cmds.register("exportfeed", middlewareLoggedIn(exportFeedHandler))
+	cmds.register("categories", middlewareLoggedIn(categoriesHandler))
+	cmds.register("addcategory", middlewareLoggedIn(addCategoryHandler))
+	cmds.register("renamecategory", middlewareLoggedIn(renameCategoryHandler))
+	cmds.register("deletecategory", middlewareLoggedIn(deleteCategoryHandler))
+	cmds.register("categorize", middlewareLoggedIn(categorizeHandler))
+	cmds.register("uncategorize", middlewareLoggedIn(uncategorizeHandler))
+	cmds.register("movefeed", middlewareLoggedIn(moveFeedHandler))
+	cmds.register("migrate", migrateHandler)
+	cmds.register("feed-status", feedStatusHandler)
 	if len(os.Args) < 2 {
 		fmt.Println("No commands found")
 		os.Exit(1)
 	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Only the first signal is handled; stopping restores the default
+	// behaviour, so a second Ctrl-C kills a command that is slow to finish.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 	cmd := command{strings.ToLower(os.Args[1]), os.Args[2:]}
-	if err = cmds.run(&s, cmd); err != nil {
+	if cmd.name != "migrate" {
+		if err = checkSchema(ctx, &s); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = cmds.run(ctx, &s, cmd); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func loginHandler(s *state, cmd command) error {
+func loginHandler(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) != 1 {
 		return errors.New("usage: <Username>")
 	}
-	user, err := s.db.GetUser(context.Background(), cmd.args[0])
+	user, err := s.db.GetUser(ctx, cmd.args[0])
 	if err != nil {
 		return errors.New("user not found")
 	}
-	err = s.cfg.SetUser(user.Name)
+	if err = checkKeyOwner(ctx, s, user); err != nil {
+		return err
+	}
+	var key string
+	if user.ApiKeyHash.Valid {
+		key = os.Getenv(apiKeyEnv)
+	}
+	err = s.cfg.SetUser(user.Name, key)
 	if err != nil {
 		return err
 	}
@@ -116,29 +161,36 @@ func loginHandler(s *state, cmd command) error {
 	return nil
 }
 
-func registerHandler(s *state, cmd command) error {
+func registerHandler(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) != 1 {
 		return errors.New("uasge: register <Username>")
 	}
-	user, err := s.db.CreateUser(context.Background(), database.CreateUserParams{
-		ID:        uuid.New(),
-		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		Name:      cmd.args[0],
+	key, hash, err := newAPIKey()
+	if err != nil {
+		return err
+	}
+	user, err := s.db.CreateUser(ctx, database.CreateUserParams{
+		ID:              uuid.New(),
+		CreatedAt:       sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt:       sql.NullTime{Time: time.Now(), Valid: true},
+		Name:            cmd.args[0],
+		ApiKeyHash:      hash,
+		ApiKeyCreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
 	})
 	if err != nil {
 		return err
 	}
-	err = s.cfg.SetUser(user.Name)
+	err = s.cfg.SetUser(user.Name, key)
 	if err != nil {
 		return err
 	}
 	fmt.Println(user.Name + " registered")
+	fmt.Println("API key (shown only once):", key)
 	return nil
 }
 
-func resetHandler(s *state, cmd command, user database.User) error {
-	err := s.db.Reset(context.Background())
+func resetHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	err := s.db.Reset(ctx)
 	if err != nil {
 		return err
 	}
@@ -146,8 +198,8 @@ func resetHandler(s *state, cmd command, user database.User) error {
 	return nil
 }
 
-func getUsersHandler(s *state, cmd command) error {
-	users, err := s.db.GetUsers(context.Background())
+func getUsersHandler(ctx context.Context, s *state, cmd command) error {
+	users, err := s.db.GetUsers(ctx)
 	if err != nil {
 		return err
 	}
@@ -164,95 +216,385 @@ func getUsersHandler(s *state, cmd command) error {
 	return nil
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+func aggHandler(ctx context.Context, s *state, cmd command) error {
+	fs := flag.NewFlagSet("agg", flag.ContinueOnError)
+	grace := fs.Duration("grace", defaultShutdownGrace, "how long feeds in flight may take to finish after a shutdown signal")
+	metricsAddr := fs.String("metrics", "", "serve Prometheus metrics on this address (:9090 or smth)")
+	args, err := parseFlags(fs, cmd.args)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req.Header.Set("User-Agent", "gator")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: agg [--grace 30s] [--metrics Addr] <Time(2m or 20s or smth)> [Workers]")
 	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
+	timebetweenRequests, err := time.ParseDuration(args[0])
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var rss RSSFeed
-	err = xml.Unmarshal(data, &rss)
-	if err != nil {
-		return nil, err
+	if timebetweenRequests <= 0 {
+		return errors.New("time between requests must be positive")
+	}
+	workers := 1
+	if len(args) == 2 {
+		workers, err = strconv.Atoi(args[1])
+		if err != nil || workers < 1 {
+			return errors.New("workers must be a positive number")
+		}
+	}
+	fmt.Printf("collectin feed every %v with %d workers\n", timebetweenRequests, workers)
+	if *metricsAddr != "" {
+		fmt.Printf("serving metrics on %s/metrics\n", *metricsAddr)
+		go s.metrics.serve(ctx, s, *metricsAddr)
 	}
-	return &rss, nil
+	// Workers stop claiming feeds as soon as ctx is cancelled. The feeds they
+	// are scraping at that point run on work, which outlives ctx by the grace
+	// period, so their posts and bookkeeping are written in full.
+	work, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	summary := &aggSummary{started: time.Now()}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			aggWorker(ctx, work, s, timebetweenRequests, summary)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	<-ctx.Done()
+	fmt.Printf("shutting down, waiting up to %v for feeds in flight\n", *grace)
+	select {
+	case <-done:
+	case <-time.After(*grace):
+		fmt.Println("grace period over, cancelling feeds in flight")
+		cancelWork()
+		<-done
+	}
+	fmt.Println(summary)
+	return nil
 }
 
-func aggHandler(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return errors.New("usage: agg <Time(2m or 20s or smth)>")
-	}
-	timebetweenRequests, err := time.ParseDuration(cmd.args[0])
-	if err != nil {
-		return err
+// aggSummary counts what an agg run did, for the report printed on exit.
+type aggSummary struct {
+	mu          sync.Mutex
+	started     time.Time
+	fetched     int
+	notModified int
+	failed      int
+	interrupted int
+	newPosts    int
+	updated     int
+}
+
+func (a *aggSummary) record(stats scrapeStats, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case err != nil:
+		a.failed++
+	case stats.NotModified:
+		a.notModified++
+	default:
+		a.fetched++
+		a.newPosts += stats.New
+		a.updated += stats.Updated
 	}
-	fmt.Printf("collectin feed every %v\n", timebetweenRequests)
-	ticker := time.NewTicker(timebetweenRequests)
-	defer ticker.Stop()
-	for ; ; <-ticker.C {
-		err = scrapeFeed()
+}
+
+func (a *aggSummary) recordInterrupted() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.interrupted++
+}
+
+func (a *aggSummary) String() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return fmt.Sprintf("agg ran for %v: %d feeds fetched, %d not modified, %d failed, %d interrupted; %d new posts, %d updated",
+		time.Since(a.started).Round(time.Second), a.fetched, a.notModified, a.failed, a.interrupted, a.newPosts, a.updated)
+}
+
+// aggWorker keeps claiming feeds that are due and scrapes them. A feed is
+// due when its next_fetch_at has passed; feeds without their own interval
+// are rescheduled one agg interval after each fetch. When nothing is due the
+// worker polls again after a fraction of the interval. Claims use SKIP
+// LOCKED, so any number of workers and agg processes can share the same
+// database.
+//
+// A failing feed never stops the worker: the error is logged and stored on
+// the feed, and the worker moves on to the next one. The worker returns
+// once ctx is cancelled; the feed it is scraping then still runs on work.
+func aggWorker(ctx, work context.Context, s *state, interval time.Duration, summary *aggSummary) {
+	poll := min(max(interval/10, time.Second), time.Minute)
+	for ctx.Err() == nil {
+		now := time.Now()
+		feed, err := s.db.ClaimNextFeedToFetch(ctx, database.ClaimNextFeedToFetchParams{
+			LeaseUntil: sql.NullTime{Time: now.Add(feedClaimLease), Valid: true},
+			Now:        sql.NullTime{Time: now, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			sleepCtx(ctx, poll)
+			continue
+		}
 		if err != nil {
-			return err
+			if ctx.Err() == nil {
+				log.Printf("claiming next feed: %v", err)
+				sleepCtx(ctx, poll)
+			}
+			continue
 		}
+		started := time.Now()
+		stats, err := scrapeFeed(work, s, feed, interval)
+		took := time.Since(started)
+		if err != nil && work.Err() != nil {
+			// Cut off at the end of the grace period. This is not the feed's
+			// fault, so no failure is recorded; its claim lease runs out and
+			// the next agg run picks it up again.
+			log.Printf("%s: interrupted by shutdown", feed.Name)
+			summary.recordInterrupted()
+			s.metrics.observeFetch("interrupted", took, stats)
+			continue
+		}
+		outcome := "ok"
+		switch {
+		case err != nil:
+			outcome = "error"
+			recordFeedFailure(work, s, feed, feedInterval(feed, interval), err)
+		case stats.NotModified:
+			outcome = "not_modified"
+		}
+		s.metrics.observeFetch(outcome, took, stats)
+		summary.record(stats, err)
 	}
 }
 
-func addFeedHandler(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 2 {
-		return errors.New("usage <FeedName> <FeedURL>")
+// sleepCtx sleeps for d or until ctx is cancelled, whichever comes first.
+func sleepCtx(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
 	}
-	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
-		ID:        uuid.New(),
-		Name:      cmd.args[0],
-		Url:       cmd.args[1],
-		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		UserID:    user.ID,
+}
+
+// recordFeedFailure stores the error on the feed and backs it off
+// exponentially, starting at twice the feed's interval. After the configured
+// number of consecutive failures the feed is disabled until enablefeed is
+// run for it.
+func recordFeedFailure(ctx context.Context, s *state, feed database.Feed, interval time.Duration, fetchErr error) {
+	failures := int(feed.ConsecutiveFailures) + 1
+	backoff := interval
+	for i := 0; i < failures && backoff < maxFeedBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxFeedBackoff)
+	maxFailures := s.cfg.MaxFeedFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFeedFailures
+	}
+	now := time.Now()
+	disabledAt := sql.NullTime{}
+	if failures >= maxFailures {
+		disabledAt = sql.NullTime{Time: now, Valid: true}
+		log.Printf("fetching %s (%s) failed %d times in a row, disabling it: %v", feed.Name, feed.Url, failures, fetchErr)
+	} else {
+		log.Printf("fetching %s (%s) failed %d times in a row, retrying in %v: %v", feed.Name, feed.Url, failures, backoff, fetchErr)
+	}
+	err := s.db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		UpdatedAt:   sql.NullTime{Time: now, Valid: true},
+		LastError:   sql.NullString{String: fetchErr.Error(), Valid: true},
+		NextFetchAt: sql.NullTime{Time: now.Add(backoff), Valid: true},
+		DisabledAt:  disabledAt,
+		ID:          feed.ID,
 	})
+	if err != nil {
+		log.Printf("recording failure for %s: %v", feed.Url, err)
+	}
+}
 
+func addFeedHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("addfeed", flag.ContinueOnError)
+	noVerify := fs.Bool("no-verify", false, "add the URL as is without fetching it")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 || *noVerify && len(args) != 2 {
+		return errors.New("usage addfeed [--no-verify] [FeedName] <FeedURL or site URL> (FeedName is required with --no-verify)")
+	}
+	name, feedURL := "", args[len(args)-1]
+	if len(args) == 2 {
+		name = args[0]
+	}
+	feed, err := createFeed(ctx, s, user, name, feedURL, !*noVerify, func(candidates []feedCandidate) (feedCandidate, error) {
+		return chooseFeed(candidates, os.Stdin, os.Stdout)
+	})
 	if err != nil {
-		return errors.New("сant create feed")
+		return err
 	}
 	fmt.Printf("New feed %s created with URL %s\n", feed.Name, feed.Url)
-	url := cmd.args[1]
+	url := feed.Url
 	followcmd := command{name: "follow", args: []string{url}}
-	if err = followHandler(s, followcmd, user); err != nil {
+	if err = followHandler(ctx, s, followcmd, user); err != nil {
 		return err
 	}
 	return nil
 }
 
-func feedsHandler(s *state, cmd command) error {
-	feeds, err := s.db.Feeds(context.Background())
+// createFeed stores a new feed owned by user. With verify set, feedURL may
+// be a site URL: the feed is discovered, parsed, and its title, description
+// and site link fill in what was not given. choose picks one feed when the
+// site offers several.
+func createFeed(ctx context.Context, s *state, user database.User, name, feedURL string, verify bool, choose func([]feedCandidate) (feedCandidate, error)) (database.Feed, error) {
+	params := database.CreateFeedParams{
+		ID:        uuid.New(),
+		Name:      name,
+		Url:       feedURL,
+		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    user.ID,
+	}
+	if verify {
+		candidates, err := s.fetcher.discoverFeeds(ctx, feedURL)
+		if err != nil {
+			return database.Feed{}, fmt.Errorf("%s is not a usable feed: %w (use --no-verify to add it anyway)", feedURL, err)
+		}
+		if len(candidates) == 0 {
+			return database.Feed{}, fmt.Errorf("no feed found at %s: it is not a feed and does not link to one (use --no-verify to add it anyway)", feedURL)
+		}
+		candidate := candidates[0]
+		if len(candidates) > 1 {
+			candidate, err = choose(candidates)
+			if err != nil {
+				return database.Feed{}, err
+			}
+		}
+		channel := candidate.Feed.Channel
+		params.Url = candidate.URL
+		if params.Name == "" {
+			params.Name = strings.TrimSpace(html.UnescapeString(channel.Title))
+		}
+		description := strings.TrimSpace(html.UnescapeString(channel.Description))
+		params.Description = sql.NullString{String: description, Valid: description != ""}
+		siteURL := strings.TrimSpace(channel.Link)
+		params.SiteUrl = sql.NullString{String: siteURL, Valid: siteURL != ""}
+	}
+	if params.Name == "" {
+		params.Name = params.Url
+	}
+	feed, err := s.db.CreateFeed(ctx, params)
+	if err != nil {
+		return database.Feed{}, errors.New("сant create feed")
+	}
+	return feed, nil
+}
+
+func feedsHandler(ctx context.Context, s *state, cmd command) error {
+	feeds, err := s.db.Feeds(ctx)
 	if err != nil {
 		return err
 	}
 	for _, feed := range feeds {
-		fmt.Println(feed.Name, feed.Url, feed.Name_2)
+		fmt.Println(feed.Name, feed.Url, feed.Name_2, feedSchedule(feed), feedHealth(feed))
 	}
 	return nil
 
 }
 
-func followHandler(s *state, cmd command, user database.User) error {
+func feedHealth(feed database.FeedsRow) string {
+	switch {
+	case feed.DisabledAt.Valid:
+		return fmt.Sprintf("[disabled since %s after %d failures: %s]",
+			feed.DisabledAt.Time.UTC().Format(time.DateTime), feed.ConsecutiveFailures, feed.LastError.String)
+	case feed.ConsecutiveFailures > 0:
+		return fmt.Sprintf("[failing, %d in a row, next try %s: %s]",
+			feed.ConsecutiveFailures, feed.NextFetchAt.Time.UTC().Format(time.DateTime), feed.LastError.String)
+	case !feed.LastFetchedAt.Valid:
+		return "[never fetched]"
+	default:
+		return "[ok]"
+	}
+}
+
+func feedSchedule(feed database.FeedsRow) string {
+	switch {
+	case feed.FetchIntervalSeconds.Valid:
+		return fmt.Sprintf("[every %v]", time.Duration(feed.FetchIntervalSeconds.Int32)*time.Second)
+	case feed.HintedIntervalSeconds.Valid:
+		return fmt.Sprintf("[every %v, from feed]", time.Duration(feed.HintedIntervalSeconds.Int32)*time.Second)
+	default:
+		return "[agg default]"
+	}
+}
+
+func setIntervalHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 2 {
+		return errors.New("usage: setinterval <FeedURL> <Time(30m or 6h or smth)|auto>")
+	}
+	feed, err := s.db.GetFeedbyurl(ctx, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	interval := sql.NullInt32{}
+	if cmd.args[1] != "auto" {
+		d, err := time.ParseDuration(cmd.args[1])
+		if err != nil {
+			return err
+		}
+		if d < time.Second || d > math.MaxInt32*time.Second {
+			return errors.New("interval out of range")
+		}
+		interval = sql.NullInt32{Int32: int32(d / time.Second), Valid: true}
+	}
+	err = s.db.SetFeedInterval(ctx, database.SetFeedIntervalParams{
+		UpdatedAt:            sql.NullTime{Time: time.Now(), Valid: true},
+		FetchIntervalSeconds: interval,
+		ID:                   feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if interval.Valid {
+		fmt.Printf("%s is now fetched every %v\n", feed.Name, time.Duration(interval.Int32)*time.Second)
+	} else {
+		fmt.Printf("%s is now fetched on the feed's own schedule\n", feed.Name)
+	}
+	return nil
+}
+
+func enableFeedHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: enablefeed <FeedURL>")
+	}
+	feed, err := s.db.GetFeedbyurl(ctx, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	err = s.db.EnableFeed(ctx, database.EnableFeedParams{
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:        feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s enabled\n", feed.Name)
+	return nil
+}
+
+func followHandler(ctx context.Context, s *state, cmd command, user database.User) error {
 	if len(cmd.args) != 1 {
 		return errors.New("usage: follow <FeedURl>")
 	}
-	feed, err := s.db.GetFeedbyurl(context.Background(), cmd.args[0])
+	feed, err := s.db.GetFeedbyurl(ctx, cmd.args[0])
 	if err != nil {
 		return err
 	}
-	_, err = s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
+	_, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
 		ID:        uuid.New(),
 		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
 		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
@@ -266,100 +608,439 @@ func followHandler(s *state, cmd command, user database.User) error {
 	return nil
 }
 
-func followingHandler(s *state, cmd command, user database.User) error {
-	follows, err := s.db.GetUsersFollowList(context.Background(), user.ID)
+// followingHandler lists the followed feeds grouped by category, or only
+// the feeds of one category with --category.
+func followingHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("following", flag.ContinueOnError)
+	only := fs.String("category", "", "only list feeds in this category")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("usage: following [--category Name]")
+	}
+	follows, err := s.db.GetUsersFollowList(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	categoryRows, err := s.db.GetUserFollowCategories(ctx, user.ID)
 	if err != nil {
 		return err
 	}
+	if len(categoryRows) == 0 && *only == "" {
+		for _, follow := range follows {
+			fmt.Println(follow.Name)
+		}
+		return nil
+	}
+	names := map[uuid.UUID]string{}
 	for _, follow := range follows {
-		fmt.Println(follow.Name)
+		names[follow.FeedID] = follow.Name
+	}
+	categorized := map[uuid.UUID]bool{}
+	var group string
+	for _, row := range categoryRows {
+		categorized[row.FeedID] = true
+		if *only != "" && row.Name != *only {
+			continue
+		}
+		if row.Name != group {
+			group = row.Name
+			fmt.Println(group + ":")
+		}
+		fmt.Println("  " + names[row.FeedID])
+	}
+	if *only != "" {
+		if group == "" {
+			fmt.Printf("no feeds in %s\n", *only)
+		}
+		return nil
+	}
+	printedHeader := false
+	for _, follow := range follows {
+		if categorized[follow.FeedID] {
+			continue
+		}
+		if !printedHeader {
+			fmt.Println("Uncategorized:")
+			printedHeader = true
+		}
+		fmt.Println("  " + follow.Name)
 	}
 	return nil
 }
 
-func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
-	return func(s *state, cmd command) error {
+// middlewareLoggedIn resolves the acting user: the owner of the API key in
+// GATOR_API_KEY when it is set, otherwise the user logged in through the
+// config file.
+// middlewareLoggedIn resolves the user from GATOR_API_KEY or, failing that,
+// from the key login stored in the config file. The user name in the config
+// file is only trusted for users without a key.
+func middlewareLoggedIn(handler func(ctx context.Context, s *state, cmd command, user database.User) error) func(context.Context, *state, command) error {
+	return func(ctx context.Context, s *state, cmd command) error {
+		key, ok := os.LookupEnv(apiKeyEnv)
+		if !ok {
+			key = s.cfg.APIKey
+		}
+		if ok || key != "" {
+			user, err := userByAPIKey(ctx, s, key)
+			if err != nil {
+				return err
+			}
+			return handler(ctx, s, cmd, user)
+		}
 		if s.cfg.CurrentUserName == "" {
 			return errors.New("no user logged in")
 		}
-		user, err := s.db.GetUser(context.Background(), s.cfg.CurrentUserName)
+		user, err := s.db.GetUser(ctx, s.cfg.CurrentUserName)
 		if err != nil {
 			return err
 		}
-		return handler(s, cmd, user)
+		if user.ApiKeyHash.Valid {
+			return fmt.Errorf("%s has an API key, log in again with %s set to it", user.Name, apiKeyEnv)
+		}
+		return handler(ctx, s, cmd, user)
 	}
 }
 
-func unfollowHandler(s *state, cmd command, user database.User) error {
+func unfollowHandler(ctx context.Context, s *state, cmd command, user database.User) error {
 	if len(cmd.args) != 1 {
 		return errors.New("usage: Unfollow <FeedURL>")
 	}
-	feed, err := s.db.GetFeedbyurl(context.Background(), cmd.args[0])
+	feed, err := s.db.GetFeedbyurl(ctx, cmd.args[0])
 	if err != nil {
 		return err
 	}
-	err = s.db.DeleteFollow(context.Background(), database.DeleteFollowParams{
+	deleted, err := s.db.DeleteFollow(ctx, database.DeleteFollowParams{
 		UserID: user.ID,
 		FeedID: feed.ID,
 	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("%s doesn't follow %s", user.Name, feed.Name)
+	}
 	fmt.Printf("%s Unfollowed on %s\n", user.Name, feed.Name)
 	return nil
 }
 
-func scrapeFeed() error {
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+// scrapeStats is what one scrapeFeed call stored.
+type scrapeStats struct {
+	NotModified bool
+	Items       int
+	New         int
+	Updated     int
+}
+
+func scrapeFeed(ctx context.Context, s *state, feed database.Feed, interval time.Duration) (stats scrapeStats, err error) {
+	started := time.Now()
+	var result fetchResult
+	defer func() {
+		recordFeedFetch(ctx, s, feed, started, result, stats, err)
+	}()
+	result, err = s.fetcher.fetchFeed(ctx, feed.Url, feed.Etag.String, feed.LastModified.String)
 	if err != nil {
-		return err
+		return stats, err
 	}
-	rss, err := fetchFeed(context.Background(), feed.Url)
+	hint := feed.HintedIntervalSeconds
+	if result.Feed != nil {
+		seconds := min(intervalHint(result.Feed)/time.Second, math.MaxInt32)
+		hint = sql.NullInt32{Int32: int32(seconds), Valid: seconds > 0}
+		feed.HintedIntervalSeconds = hint
+	}
+	// The fetch bookkeeping and the posts commit together, so a crash or a
+	// failing insert never leaves a feed marked fetched with only part of
+	// its posts stored.
+	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return stats, err
 	}
-	err = s.db.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
-		UpdatedAt:     sql.NullTime{Time: time.Now(), Valid: true},
-		LastFetchedAt: sql.NullTime{Time: time.Now(), Valid: true},
-		ID:            feed.ID,
+	defer tx.Rollback()
+	// Built like s.db rather than with WithTx so that queries in the
+	// transaction are counted by the metrics too.
+	qtx := database.New(s.metrics.instrumentDB(tx))
+	now := time.Now()
+	err = qtx.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+		UpdatedAt:             sql.NullTime{Time: now, Valid: true},
+		LastFetchedAt:         sql.NullTime{Time: now, Valid: true},
+		NextFetchAt:           sql.NullTime{Time: nextFetchTime(now, feedInterval(feed, interval), result.Feed), Valid: true},
+		Etag:                  sql.NullString{String: result.ETag, Valid: result.ETag != ""},
+		LastModified:          sql.NullString{String: result.LastModified, Valid: result.LastModified != ""},
+		HintedIntervalSeconds: hint,
+		ID:                    feed.ID,
 	})
 	if err != nil {
-		return err
+		return stats, err
 	}
-	for i := range rss.Channel.Item {
-		pubTime, err := time.Parse(TimeLayout, rss.Channel.Item[i].PubDate)
+	if !result.NotModified {
+		if stats, err = storePosts(ctx, qtx, feed, result.Feed); err != nil {
+			return scrapeStats{}, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return scrapeStats{}, err
+	}
+	// The URL is updated outside the transaction: a failure here, such as
+	// the new URL already belonging to another feed, must not undo the fetch.
+	if result.PermanentURL != "" && result.PermanentURL != feed.Url {
+		err = s.db.UpdateFeedURL(ctx, database.UpdateFeedURLParams{
+			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			Url:       result.PermanentURL,
+			ID:        feed.ID,
+		})
+		if err != nil {
+			log.Printf("%s moved permanently to %s but the URL could not be updated: %v", feed.Url, result.PermanentURL, err)
+		} else {
+			log.Printf("%s moved permanently to %s", feed.Url, result.PermanentURL)
+		}
+	}
+	if result.NotModified {
+		stats.NotModified = true
+		return stats, nil
+	}
+	stats.Items = len(result.Feed.Channel.Item)
+	log.Printf("%s: %d new, %d updated", feed.Name, stats.New, stats.Updated)
+	return stats, nil
+}
+
+// storePosts upserts the feed's items in batches of postBatchSize. Items
+// repeating a guid already seen in the same document are dropped, since
+// one statement can't upsert the same row twice.
+func storePosts(ctx context.Context, q *database.Queries, feed database.Feed, rss *RSSFeed) (scrapeStats, error) {
+	var stats scrapeStats
+	fetchedAt := time.Now()
+	estimated := 0
+	seen := make(map[string]bool, len(rss.Channel.Item))
+	var batch database.UpsertPostsParams
+	flush := func() error {
+		if len(batch.Guids) == 0 {
+			return nil
+		}
+		batch.Now = time.Now()
+		batch.FeedID = feed.ID
+		// Posts stored before guids were tracked got their URL as guid.
+		// Give them the item's real guid first so the upsert updates them
+		// instead of inserting a copy without the user's read state.
+		if _, err := q.AdoptLegacyPostGuids(ctx, database.AdoptLegacyPostGuidsParams{
+			Guids:  batch.Guids,
+			Urls:   batch.Urls,
+			FeedID: feed.ID,
+		}); err != nil {
+			return err
+		}
+		// Unchanged items are not returned; the rest report whether they
+		// were inserted or updated.
+		inserted, err := q.UpsertPosts(ctx, batch)
 		if err != nil {
 			return err
 		}
-		err = s.db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   sql.NullTime{Time: time.Now(), Valid: true},
-			UpdatedAt:   sql.NullTime{Time: time.Now(), Valid: true},
-			PublishedAt: sql.NullTime{Time: pubTime, Valid: true},
-			Title:       html.UnescapeString(rss.Channel.Item[i].Title),
-			Url:         rss.Channel.Item[i].Link,
-			Description: sql.NullString{String: html.UnescapeString(rss.Channel.Item[i].Description), Valid: true},
-			FeedID:      feed.ID,
-		})
+		for _, isNew := range inserted {
+			if isNew {
+				stats.New++
+			} else {
+				stats.Updated++
+			}
+		}
+		batch = database.UpsertPostsParams{}
+		return nil
+	}
+	for i := range rss.Channel.Item {
+		item := rss.Channel.Item[i]
+		guid := item.identity()
+		if guid == "" || seen[guid] {
+			continue
+		}
+		seen[guid] = true
+		pubTime, pubTimeEstimated := parsePubDate(item.PubDate, fetchedAt)
+		if pubTimeEstimated {
+			estimated++
+		}
+		batch.Ids = append(batch.Ids, uuid.New())
+		batch.PublishedAts = append(batch.PublishedAts, pubTime)
+		batch.Titles = append(batch.Titles, html.UnescapeString(item.Title))
+		batch.Urls = append(batch.Urls, item.Link)
+		batch.Descriptions = append(batch.Descriptions, html.UnescapeString(item.Description))
+		batch.PublishedAtEstimated = append(batch.PublishedAtEstimated, pubTimeEstimated)
+		batch.Guids = append(batch.Guids, guid)
+		if len(batch.Guids) == postBatchSize {
+			if err := flush(); err != nil {
+				return scrapeStats{}, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return scrapeStats{}, err
+	}
+	if estimated > 0 {
+		log.Printf("%s: %d of %d items had no usable publish date, used fetch time", feed.Name, estimated, len(rss.Channel.Item))
+	}
+	return stats, nil
+}
 
+func GetPostshandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("getposts", flag.ContinueOnError)
+	unread := fs.Bool("unread", false, "only show unread posts")
+	starred := fs.Bool("starred", false, "only show starred posts")
+	archived := fs.Bool("archived", false, "include archived posts")
+	category := fs.String("category", "", "only show posts from feeds in this category")
+	feed := fs.String("feed", "", "only show posts from this feed, by URL or name")
+	since := fs.String("since", "", "only show posts published since this date or age (24h, 7d, 2026-01-02)")
+	before := fs.String("before", "", "only show posts published before this date or age")
+	title := fs.String("title", "", "only show posts whose title contains this text")
+	sortOrder := fs.String("sort", "newest", "sort order: newest or oldest")
+	offset := fs.Int("offset", 0, "number of posts to skip")
+	cursor := fs.String("cursor", "", "continue after the page that printed this cursor")
+	group := fs.Bool("group", false, "group the posts by category")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return errors.New("usage: GetPosts [--unread] [--starred] [--archived] [--category Name] [--feed URL|Name] [--since T] [--before T] [--title Text] [--sort newest|oldest] [--offset N] [--cursor C] [--group] [Limit]")
+	}
+	Limit := 10
+	if len(args) == 1 {
+		Limit, err = strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+	}
+	if Limit < 1 {
+		return errors.New("limit must be a positive number")
+	}
+	if *offset < 0 {
+		return errors.New("offset can't be negative")
+	}
+	if *sortOrder != "newest" && *sortOrder != "oldest" {
+		return fmt.Errorf("unknown sort order %q: want newest or oldest", *sortOrder)
+	}
+	params := database.GetFilteredPostsParams{
+		UserID:          user.ID,
+		UnreadOnly:      *unread,
+		StarredOnly:     *starred,
+		IncludeArchived: *archived,
+		Category:        sql.NullString{String: *category, Valid: *category != ""},
+		Feed:            sql.NullString{String: *feed, Valid: *feed != ""},
+		Title:           sql.NullString{String: escapeLike(*title), Valid: *title != ""},
+		OldestFirst:     *sortOrder == "oldest",
+		Limit:           int32(Limit),
+		Offset:          int32(*offset),
+	}
+	now := time.Now()
+	if *since != "" {
+		t, err := parseTimeBound(*since, now)
+		if err != nil {
+			return err
+		}
+		params.Since = sql.NullTime{Time: t, Valid: true}
+	}
+	if *before != "" {
+		t, err := parseTimeBound(*before, now)
+		if err != nil {
+			return err
+		}
+		params.Before = sql.NullTime{Time: t, Valid: true}
+	}
+	if *cursor != "" {
+		c, err := parsePostCursor(*cursor)
+		if err != nil {
+			return err
+		}
+		params.CursorPublishedAt = sql.NullTime{Time: c.PublishedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: c.ID, Valid: true}
+	}
+	posts, err := s.db.GetFilteredPosts(ctx, params)
+	if err != nil {
+		return err
+	}
+	if *group {
+		if err := printPostsByCategory(ctx, s, user, posts, *category); err != nil {
+			return err
+		}
+	} else {
+		for _, post := range posts {
+			printPost(post)
+		}
+	}
+	if len(posts) > 0 && len(posts) == Limit {
+		last := posts[len(posts)-1]
+		next := postCursor{PublishedAt: time.Unix(0, 0).UTC(), ID: last.ID}
+		if last.PublishedAt.Valid {
+			next.PublishedAt = last.PublishedAt.Time
+		}
+		fmt.Println("========================================")
+		fmt.Println("Next page: --cursor", next)
 	}
 	return nil
 }
 
-func GetPostshandler(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return errors.New("usage: GetPosts <Limit>")
-	}
-	Limit, err := strconv.Atoi(cmd.args[0])
+// printPostsByCategory prints the page under a heading per category, like
+// following does. A post from a feed in several categories is listed under
+// each of them; with --category only that category is shown.
+func printPostsByCategory(ctx context.Context, s *state, user database.User, posts []database.GetFilteredPostsRow, only string) error {
+	categoryRows, err := s.db.GetUserFollowCategories(ctx, user.ID)
 	if err != nil {
 		return err
 	}
-	posts, err := s.db.GetPosts(context.Background(), database.GetPostsParams{
-		UserID: user.ID,
-		Limit:  int32(Limit),
-	})
+	var names []string
+	feedCategories := map[uuid.UUID][]string{}
+	for _, row := range categoryRows {
+		if len(names) == 0 || names[len(names)-1] != row.Name {
+			names = append(names, row.Name)
+		}
+		feedCategories[row.FeedID] = append(feedCategories[row.FeedID], row.Name)
+	}
+	groups := map[string][]database.GetFilteredPostsRow{}
 	for _, post := range posts {
-		fmt.Println("========================================")
-		fmt.Println("Title:", post.Title, "\nPublished:", post.PublishedAt.Time.UTC().Format(time.DateTime))
-		fmt.Println("Description:", post.Description.String)
-		fmt.Println("URL:", post.Url)
+		if len(feedCategories[post.FeedID]) == 0 {
+			groups[""] = append(groups[""], post)
+		}
+		for _, name := range feedCategories[post.FeedID] {
+			groups[name] = append(groups[name], post)
+		}
+	}
+	for _, name := range append(names, "") {
+		if len(groups[name]) == 0 || (only != "" && name != only) {
+			continue
+		}
+		heading := name
+		if heading == "" {
+			heading = "Uncategorized"
+		}
+		fmt.Println(heading + ":")
+		for _, post := range groups[name] {
+			printPost(post)
+		}
 	}
 	return nil
 }
+
+func printPost(post database.GetFilteredPostsRow) {
+	fmt.Println("========================================")
+	fmt.Println("Title:", post.Title, postFlags(post.ReadAt, post.StarredAt, post.ArchivedAt), "\nPublished:", post.PublishedAt.Time.UTC().Format(time.DateTime))
+	fmt.Println("Feed:", post.FeedName)
+	fmt.Println("Description:", post.Description.String)
+	fmt.Println("URL:", post.Url)
+	fmt.Println("ID:", post.ID)
+}
+
+// escapeLike makes text match literally inside an ILIKE pattern.
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+func postFlags(readAt, starredAt, archivedAt sql.NullTime) string {
+	var flags []string
+	if !readAt.Valid {
+		flags = append(flags, "[unread]")
+	}
+	if starredAt.Valid {
+		flags = append(flags, "[starred]")
+	}
+	if archivedAt.Valid {
+		flags = append(flags, "[archived]")
+	}
+	return strings.Join(flags, " ")
+}
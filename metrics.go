@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+var fetchOutcomes = []string{"ok", "not_modified", "error", "interrupted"}
+
+var fetchDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metrics holds the aggregator's counters and renders them in the
+// Prometheus text exposition format. There are few enough series that a
+// client library would be more code than it saves.
+type metrics struct {
+	fetches       map[string]*atomic.Int64
+	fetchDuration histogram
+	itemsParsed   atomic.Int64
+	postsInserted atomic.Int64
+	postsUpdated  atomic.Int64
+	dbErrors      atomic.Int64
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		fetches:       make(map[string]*atomic.Int64, len(fetchOutcomes)),
+		fetchDuration: histogram{buckets: fetchDurationBuckets, counts: make([]uint64, len(fetchDurationBuckets))},
+	}
+	for _, outcome := range fetchOutcomes {
+		m.fetches[outcome] = &atomic.Int64{}
+	}
+	return m
+}
+
+// observeFetch records one scrapeFeed call. outcome is one of
+// fetchOutcomes.
+func (m *metrics) observeFetch(outcome string, took time.Duration, stats scrapeStats) {
+	m.fetches[outcome].Add(1)
+	m.fetchDuration.observe(took.Seconds())
+	m.itemsParsed.Add(int64(stats.Items))
+	m.postsInserted.Add(int64(stats.New))
+	m.postsUpdated.Add(int64(stats.Updated))
+}
+
+// handler serves the metrics. The scheduler lag is read from the database
+// on every scrape, so it is exact even with several agg processes.
+func (m *metrics) handler(s *state) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		writeMetricHeader(&buf, "gator_feed_fetches_total", "counter", "Feed fetch attempts by outcome.")
+		for _, outcome := range fetchOutcomes {
+			fmt.Fprintf(&buf, "gator_feed_fetches_total{outcome=%q} %d\n", outcome, m.fetches[outcome].Load())
+		}
+		m.fetchDuration.write(&buf, "gator_feed_fetch_duration_seconds", "Time spent fetching and storing one feed.")
+		writeMetric(&buf, "gator_items_parsed_total", "counter", "Items parsed from fetched feeds.", m.itemsParsed.Load())
+		writeMetric(&buf, "gator_posts_inserted_total", "counter", "Posts stored for the first time.", m.postsInserted.Load())
+		writeMetric(&buf, "gator_posts_updated_total", "counter", "Stored posts updated because their item changed.", m.postsUpdated.Load())
+		lag, err := s.db.GetSchedulerLag(r.Context(), time.Now())
+		if err != nil {
+			log.Printf("metrics: reading scheduler lag: %v", err)
+		} else {
+			writeMetricHeader(&buf, "gator_scheduler_lag_seconds", "gauge", "Age of the oldest last_fetched_at among enabled feeds.")
+			fmt.Fprintf(&buf, "gator_scheduler_lag_seconds %s\n", formatFloat(lag))
+		}
+		// Written last so that a failing lag query is already counted.
+		writeMetric(&buf, "gator_db_query_errors_total", "counter", "Database queries that returned an error.", m.dbErrors.Load())
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
+
+// serveMetrics runs the metrics listener until ctx is cancelled.
+func (m *metrics) serve(ctx context.Context, s *state, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", m.handler(s))
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("metrics listener: %v", err)
+	}
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeMetric(buf *bytes.Buffer, name, kind, help string, value int64) {
+	writeMetricHeader(buf, name, kind, help)
+	fmt.Fprintf(buf, "%s %d\n", name, value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(buf *bytes.Buffer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeMetricHeader(buf, name, "histogram", help)
+	for i, bound := range h.buckets {
+		fmt.Fprintf(buf, "%s_bucket{le=%q} %d\n", name, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(buf, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count %d\n", name, h.count)
+}
+
+// instrumentedDB counts the errors of every query that goes through it.
+// sql.ErrNoRows only surfaces on Scan, so an empty result is not counted.
+type instrumentedDB struct {
+	db      database.DBTX
+	metrics *metrics
+}
+
+func (m *metrics) instrumentDB(db database.DBTX) database.DBTX {
+	return instrumentedDB{db: db, metrics: m}
+}
+
+func (d instrumentedDB) count(err error) {
+	if err != nil {
+		d.metrics.dbErrors.Add(1)
+	}
+}
+
+func (d instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := d.db.ExecContext(ctx, query, args...)
+	d.count(err)
+	return result, err
+}
+
+func (d instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	stmt, err := d.db.PrepareContext(ctx, query)
+	d.count(err)
+	return stmt, err
+}
+
+func (d instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	d.count(err)
+	return rows, err
+}
+
+func (d instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := d.db.QueryRowContext(ctx, query, args...)
+	d.count(row.Err())
+	return row
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yourgfslove/BLOGagregator/internal/migrate"
+	"github.com/yourgfslove/BLOGagregator/sql/schema"
+)
+
+func newMigrator(s *state) (*migrate.Migrator, error) {
+	return migrate.New(s.conn, schema.FS)
+}
+
+func migrateHandler(ctx context.Context, s *state, cmd command) error {
+	usage := errors.New("usage: migrate <up|down|status|to <Version>>")
+	if len(cmd.args) == 0 {
+		return usage
+	}
+	migrator, err := newMigrator(s)
+	if err != nil {
+		return err
+	}
+	switch cmd.args[0] {
+	case "up":
+		if len(cmd.args) != 1 {
+			return usage
+		}
+		done, err := migrator.Up(ctx)
+		printMigrations("applied", done)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return nil
+	case "down":
+		if len(cmd.args) != 1 {
+			return usage
+		}
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("rolled back", migration.Name)
+		return nil
+	case "to":
+		if len(cmd.args) != 2 {
+			return usage
+		}
+		version, err := strconv.ParseInt(cmd.args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", cmd.args[1])
+		}
+		current, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		done, err := migrator.To(ctx, version)
+		verb := "applied"
+		if version < current {
+			verb = "rolled back"
+		}
+		printMigrations(verb, done)
+		return err
+	case "status":
+		if len(cmd.args) != 1 {
+			return usage
+		}
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%-20s %s\n", applied, status.Name)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
+
+func printMigrations(verb string, migrations []migrate.Migration) {
+	for _, migration := range migrations {
+		fmt.Println(verb, migration.Name)
+	}
+}
+
+// checkSchema refuses to run commands against a database that is missing
+// migrations this binary relies on.
+func checkSchema(ctx context.Context, s *state) error {
+	migrator, err := newMigrator(s)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("checking the database schema: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date: %d migrations pending, the first is %s; run `gator migrate up`", len(pending), pending[0].Name)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// opmlSubscription is a feed outline together with the folders it was
+// nested in, joined with "/" into category names.
+type opmlSubscription struct {
+	outline    opmlOutline
+	categories []string
+}
+
+func importOPMLHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: import-opml <File>")
+	}
+	data, err := os.ReadFile(cmd.args[0])
+	if err != nil {
+		return err
+	}
+	var doc opmlDocument
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid OPML: %w", err)
+	}
+	subscriptions := collectSubscriptions(doc.Body.Outlines, nil, nil)
+	if len(subscriptions) == 0 {
+		return errors.New("no feeds found in " + cmd.args[0])
+	}
+	created, followed, failed := 0, 0, 0
+	for _, sub := range subscriptions {
+		newFeed, newFollow, err := importSubscription(ctx, s, user, sub)
+		if err != nil {
+			fmt.Printf("skipping %s: %v\n", sub.outline.XMLURL, err)
+			failed++
+			continue
+		}
+		if newFeed {
+			created++
+		}
+		if newFollow {
+			followed++
+		}
+	}
+	fmt.Printf("imported %d feeds: %d new feeds, %d new follows, %d failed\n", len(subscriptions), created, followed, failed)
+	return nil
+}
+
+func collectSubscriptions(outlines []opmlOutline, path []string, subscriptions []opmlSubscription) []opmlSubscription {
+	for _, outline := range outlines {
+		name := outline.Text
+		if name == "" {
+			name = outline.Title
+		}
+		if outline.XMLURL == "" {
+			subscriptions = collectSubscriptions(outline.Outlines, append(path[:len(path):len(path)], name), subscriptions)
+			continue
+		}
+		var categories []string
+		if len(path) > 0 {
+			categories = append(categories, strings.Join(path, "/"))
+		}
+		for _, category := range strings.Split(outline.Category, ",") {
+			if category = strings.Trim(strings.TrimSpace(category), "/"); category != "" {
+				categories = append(categories, category)
+			}
+		}
+		subscriptions = append(subscriptions, opmlSubscription{outline: outline, categories: categories})
+	}
+	return subscriptions
+}
+
+// importSubscription creates the feed if nobody added it yet, follows it for
+// user and files the follow under the subscription's categories.
+func importSubscription(ctx context.Context, s *state, user database.User, sub opmlSubscription) (newFeed, newFollow bool, err error) {
+	feedURL := strings.TrimSpace(sub.outline.XMLURL)
+	feed, err := s.db.GetFeedbyurl(ctx, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		name := sub.outline.Title
+		if name == "" {
+			name = sub.outline.Text
+		}
+		if name == "" {
+			name = feedURL
+		}
+		feed, err = s.db.CreateFeed(ctx, database.CreateFeedParams{
+			ID:        uuid.New(),
+			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			Name:      name,
+			Url:       feedURL,
+			UserID:    user.ID,
+			SiteUrl:   sql.NullString{String: sub.outline.HTMLURL, Valid: sub.outline.HTMLURL != ""},
+		})
+		newFeed = err == nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	follow, err := s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		var created database.CreateFeedFollowRow
+		created, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		follow.ID = created.ID
+		newFollow = err == nil
+	}
+	if err != nil {
+		return newFeed, false, err
+	}
+	for _, name := range sub.categories {
+		category, err := s.db.UpsertCategory(ctx, database.UpsertCategoryParams{
+			ID:        uuid.New(),
+			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:    user.ID,
+			Name:      name,
+		})
+		if err != nil {
+			return newFeed, newFollow, err
+		}
+		err = s.db.AddFollowToCategory(ctx, database.AddFollowToCategoryParams{
+			FeedFollowID: follow.ID,
+			CategoryID:   category.ID,
+		})
+		if err != nil {
+			return newFeed, newFollow, err
+		}
+	}
+	return newFeed, newFollow, nil
+}
+
+func exportOPMLHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) > 1 {
+		return errors.New("usage: export-opml [File]")
+	}
+	follows, err := s.db.GetUsersFollowList(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	categoryRows, err := s.db.GetUserFollowCategories(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	categories := map[uuid.UUID][]string{}
+	for _, row := range categoryRows {
+		categories[row.FeedID] = append(categories[row.FeedID], row.Name)
+	}
+	doc := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       user.Name + " subscriptions",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, follow := range follows {
+		outline := opmlOutline{
+			Text:    follow.Name,
+			Title:   follow.Name,
+			Type:    "rss",
+			XMLURL:  follow.Url,
+			HTMLURL: follow.SiteUrl.String,
+		}
+		if len(categories[follow.FeedID]) == 0 {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			continue
+		}
+		for _, category := range categories[follow.FeedID] {
+			doc.Body.Outlines = insertOutline(doc.Body.Outlines, strings.Split(category, "/"), outline)
+		}
+	}
+	var out io.Writer = os.Stdout
+	if len(cmd.args) == 1 {
+		file, err := os.Create(cmd.args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if _, err = io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err = encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, "\n")
+	return err
+}
+
+// insertOutline adds outline under the folder path, creating the folders
+// that do not exist yet.
+func insertOutline(outlines []opmlOutline, path []string, outline opmlOutline) []opmlOutline {
+	if len(path) == 0 {
+		return append(outlines, outline)
+	}
+	for i := range outlines {
+		if outlines[i].XMLURL == "" && outlines[i].Text == path[0] {
+			outlines[i].Outlines = insertOutline(outlines[i].Outlines, path[1:], outline)
+			return outlines
+		}
+	}
+	folder := opmlOutline{Text: path[0], Title: path[0]}
+	folder.Outlines = insertOutline(nil, path[1:], outline)
+	return append(outlines, folder)
+}
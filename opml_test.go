@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const opmlFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Loose" type="rss" xmlUrl="https://loose.example/feed"/>
+    <outline text="Tech">
+      <outline text="Go">
+        <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog"/>
+        <outline text="Tagged" type="rss" xmlUrl="https://tagged.example/rss" category="/Reading/, Later ,,"/>
+      </outline>
+      <outline text="Rust">
+        <outline text="This Week in Rust" type="rss" xmlUrl="https://this-week-in-rust.org/rss.xml"/>
+      </outline>
+      <outline text="Hacker News" type="rss" xmlUrl="https://news.ycombinator.com/rss"/>
+    </outline>
+    <outline title="Titled folder">
+      <outline text="Under title" type="rss" xmlUrl="https://titled.example/feed"/>
+    </outline>
+    <outline text="Empty folder"/>
+  </body>
+</opml>`
+
+func TestCollectSubscriptions(t *testing.T) {
+	var doc opmlDocument
+	if err := xml.Unmarshal([]byte(opmlFixture), &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"https://loose.example/feed":            nil,
+		"https://go.dev/blog/feed.atom":         {"Tech/Go"},
+		"https://tagged.example/rss":            {"Tech/Go", "Reading", "Later"},
+		"https://this-week-in-rust.org/rss.xml": {"Tech/Rust"},
+		"https://news.ycombinator.com/rss":      {"Tech"},
+		"https://titled.example/feed":           {"Titled folder"},
+	}
+	if got := subscriptionCategories(collectSubscriptions(doc.Body.Outlines, nil, nil)); !reflect.DeepEqual(got, want) {
+		t.Errorf("collectSubscriptions = %v\nwant %v", got, want)
+	}
+}
+
+// TestOPMLRoundTrip builds an export the way exportOPMLHandler does and
+// checks that importing it yields the same feeds in the same categories.
+func TestOPMLRoundTrip(t *testing.T) {
+	follows := []struct {
+		url        string
+		categories []string
+	}{
+		{"https://loose.example/feed", nil},
+		{"https://go.dev/blog/feed.atom", []string{"Tech/Go"}},
+		{"https://go.dev/blog/feed.atom", []string{"Favourites"}},
+		{"https://this-week-in-rust.org/rss.xml", []string{"Tech/Rust", "Tech/Languages/Systems"}},
+		{"https://news.ycombinator.com/rss", []string{"Tech"}},
+		{"https://deep.example/feed", []string{"A/B/C/D"}},
+	}
+	doc := opmlDocument{Version: "2.0"}
+	want := map[string][]string{}
+	for _, follow := range follows {
+		outline := opmlOutline{Text: follow.url, Type: "rss", XMLURL: follow.url}
+		if len(follow.categories) == 0 {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			want[follow.url] = nil
+			continue
+		}
+		for _, category := range follow.categories {
+			doc.Body.Outlines = insertOutline(doc.Body.Outlines, strings.Split(category, "/"), outline)
+			want[follow.url] = append(want[follow.url], category)
+		}
+	}
+	data, err := xml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imported opmlDocument
+	if err := xml.Unmarshal(data, &imported); err != nil {
+		t.Fatal(err)
+	}
+	got := subscriptionCategories(collectSubscriptions(imported.Body.Outlines, nil, nil))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %v\nwant %v\nOPML: %s", got, want, data)
+	}
+	// Folders are shared, not repeated per feed.
+	var tech int
+	for _, outline := range imported.Body.Outlines {
+		if outline.Text == "Tech" {
+			tech++
+		}
+	}
+	if tech != 1 {
+		t.Errorf("export has %d Tech folders, want 1", tech)
+	}
+}
+
+// subscriptionCategories merges the subscriptions by feed URL, as
+// importSubscription does when a feed appears in several folders.
+func subscriptionCategories(subscriptions []opmlSubscription) map[string][]string {
+	categories := map[string][]string{}
+	for _, sub := range subscriptions {
+		categories[sub.outline.XMLURL] = append(categories[sub.outline.XMLURL], sub.categories...)
+	}
+	return categories
+}
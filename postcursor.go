@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A post cursor points just past the last post of a page: its publication
+// time and id, the two sort keys of GetFilteredPosts. Unlike an offset it
+// stays correct while new posts are ingested between pages.
+type postCursor struct {
+	PublishedAt time.Time
+	ID          uuid.UUID
+}
+
+func (c postCursor) String() string {
+	raw := c.PublishedAt.Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parsePostCursor(value string) (postCursor, error) {
+	invalid := errors.New("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return postCursor{}, invalid
+	}
+	published, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return postCursor{}, invalid
+	}
+	var c postCursor
+	if c.PublishedAt, err = time.Parse(time.RFC3339Nano, published); err != nil {
+		return postCursor{}, invalid
+	}
+	if c.ID, err = uuid.Parse(id); err != nil {
+		return postCursor{}, invalid
+	}
+	return c, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPostCursorRoundTrip(t *testing.T) {
+	id := uuid.MustParse("0b6f3c1e-2a4d-4e8b-9c1f-3d5e7a9b1c2d")
+	for _, published := range []time.Time{
+		time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		time.Unix(0, 0).UTC(),
+	} {
+		want := postCursor{PublishedAt: published, ID: id}
+		got, err := parsePostCursor(want.String())
+		if err != nil {
+			t.Fatalf("parsePostCursor(%q) failed: %v", want.String(), err)
+		}
+		if !got.PublishedAt.Equal(want.PublishedAt) || got.ID != want.ID {
+			t.Errorf("round trip of %+v gave %+v", want, got)
+		}
+	}
+}
+
+func TestParsePostCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	for _, value := range []string{
+		"",
+		"not base64!",
+		encode("2026-01-02T03:04:05Z"),
+		encode("yesterday|0b6f3c1e-2a4d-4e8b-9c1f-3d5e7a9b1c2d"),
+		encode("2026-01-02T03:04:05Z|not-a-uuid"),
+	} {
+		if c, err := parsePostCursor(value); err == nil {
+			t.Errorf("parsePostCursor(%q) = %+v, want an error", value, c)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+// postStateHandler builds a command that applies update to every post ID
+// given on the command line.
+func postStateHandler(name, done string, update func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error) func(context.Context, *state, command, database.User) error {
+	return func(ctx context.Context, s *state, cmd command, user database.User) error {
+		if len(cmd.args) == 0 {
+			return fmt.Errorf("usage: %s <PostID>...", name)
+		}
+		for _, arg := range cmd.args {
+			postID, err := uuid.Parse(arg)
+			if err != nil {
+				return fmt.Errorf("invalid post ID %q", arg)
+			}
+			if err = update(ctx, s, user, postID); err != nil {
+				return err
+			}
+			fmt.Println(postID, done)
+		}
+		return nil
+	}
+}
+
+var markReadHandler = postStateHandler("markread", "marked read", func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	return s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: postID,
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+})
+
+var markUnreadHandler = postStateHandler("markunread", "marked unread", func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	return s.db.MarkPostUnread(ctx, database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+})
+
+var starHandler = postStateHandler("star", "starred", func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	return s.db.StarPost(ctx, database.StarPostParams{
+		UserID:    user.ID,
+		PostID:    postID,
+		StarredAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+})
+
+var unstarHandler = postStateHandler("unstar", "unstarred", func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	return s.db.UnstarPost(ctx, database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+})
+
+var archiveHandler = postStateHandler("archive", "archived", func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	return s.db.ArchivePost(ctx, database.ArchivePostParams{
+		UserID:     user.ID,
+		PostID:     postID,
+		ArchivedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+})
+
+var unarchiveHandler = postStateHandler("unarchive", "unarchived", func(ctx context.Context, s *state, user database.User, postID uuid.UUID) error {
+	return s.db.UnarchivePost(ctx, database.UnarchivePostParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+})
+
+func markFeedReadHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.args) != 1 {
+		return errors.New("usage: markfeedread <FeedURL>")
+	}
+	feed, err := s.db.GetFeedbyurl(ctx, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	marked, err := s.db.MarkFeedRead(ctx, database.MarkFeedReadParams{
+		UserID: user.ID,
+		ReadAt: time.Now(),
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d posts in %s marked read\n", marked, feed.Name)
+	return nil
+}
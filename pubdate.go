@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// pubDateLayouts covers the date formats seen in the wild: RFC 822/1123 with
+// numeric offsets or zone names, with and without weekday, seconds or a
+// four-digit year, RFC 3339 as used by Atom and JSON Feed, and a few bare
+// ISO 8601 variants.
+var pubDateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 06 15:04:05 MST",
+	"Mon, 2 Jan 06 15:04 -0700",
+	"Mon, 2 Jan 06 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04:05 MST",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 06 15:04 MST",
+	"Mon, 2 January 2006 15:04:05 -0700",
+	"Mon, 2 January 2006 15:04:05 MST",
+	"Monday, 02-Jan-06 15:04:05 MST",
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02T15:04-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.ANSIC,
+	time.UnixDate,
+}
+
+// zoneOffsets resolves the zone abbreviations RFC 822 allows. time.Parse
+// only knows the offset of abbreviations used by the local zone and treats
+// the rest as UTC.
+var zoneOffsets = map[string]int{
+	"UT":  0,
+	"GMT": 0,
+	"Z":   0,
+	"EST": -5 * 60 * 60,
+	"EDT": -4 * 60 * 60,
+	"CST": -6 * 60 * 60,
+	"CDT": -5 * 60 * 60,
+	"MST": -7 * 60 * 60,
+	"MDT": -6 * 60 * 60,
+	"PST": -8 * 60 * 60,
+	"PDT": -7 * 60 * 60,
+}
+
+// parsePubDate parses a feed item date. When the value is empty or matches
+// none of the known layouts it returns fallback and reports that the date
+// was estimated. The result is always in UTC: posts.published_at has no
+// zone, so any offset left on the value would be dropped on insert.
+func parsePubDate(value string, fallback time.Time) (time.Time, bool) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return fallback.UTC(), true
+	}
+	for _, layout := range pubDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		return fixZone(t).UTC(), false
+	}
+	return fallback.UTC(), true
+}
+
+func fixZone(t time.Time) time.Time {
+	name, offset := t.Zone()
+	if offset != 0 {
+		return t
+	}
+	if known, ok := zoneOffsets[name]; ok && known != 0 {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone(name, known))
+	}
+	return t
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePubDate(t *testing.T) {
+	fallback := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3*60*60))
+	tests := []struct {
+		name      string
+		value     string
+		want      time.Time
+		estimated bool
+	}{
+		{"RFC 1123 numeric offset", "Mon, 06 May 2024 07:08:09 +0200", time.Date(2024, 5, 6, 5, 8, 9, 0, time.UTC), false},
+		{"RFC 1123 GMT", "Mon, 06 May 2024 07:08:09 GMT", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), false},
+		{"RFC 1123 zone name", "Mon, 06 May 2024 10:00:00 PST", time.Date(2024, 5, 6, 18, 0, 0, 0, time.UTC), false},
+		{"RFC 1123 daylight zone name", "Mon, 06 May 2024 10:00:00 EDT", time.Date(2024, 5, 6, 14, 0, 0, 0, time.UTC), false},
+		{"no weekday", "6 May 2024 07:08:09 +0000", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), false},
+		{"two-digit year", "Mon, 06 May 24 07:08:09 +0000", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), false},
+		{"no seconds", "Mon, 06 May 2024 07:08 -0500", time.Date(2024, 5, 6, 12, 8, 0, 0, time.UTC), false},
+		{"extra whitespace", "  Mon,  06 May 2024\n07:08:09 +0000 ", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), false},
+		{"RFC 3339", "2024-05-06T07:08:09+02:00", time.Date(2024, 5, 6, 5, 8, 9, 0, time.UTC), false},
+		{"RFC 3339 fractional", "2024-05-06T07:08:09.5Z", time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC), false},
+		{"ISO 8601 basic offset", "2024-05-06T07:08:09+0000", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), false},
+		{"ISO 8601 basic offset non-UTC", "2024-05-06T07:08:09-0700", time.Date(2024, 5, 6, 14, 8, 9, 0, time.UTC), false},
+		{"ISO 8601 no seconds", "2024-05-06T07:08Z", time.Date(2024, 5, 6, 7, 8, 0, 0, time.UTC), false},
+		{"date only", "2024-05-06", time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), false},
+		{"empty", "", fallback.UTC(), true},
+		{"garbage", "last Tuesday", fallback.UTC(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, estimated := parsePubDate(tt.value, fallback)
+			if !got.Equal(tt.want) || estimated != tt.estimated {
+				t.Errorf("parsePubDate(%q) = %v, %v; want %v, %v", tt.value, got, estimated, tt.want, tt.estimated)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("parsePubDate(%q) location = %v, want UTC", tt.value, got.Location())
+			}
+		})
+	}
+}
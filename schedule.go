@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+// feedClaimLease is how long a claimed feed stays reserved for the worker
+// that claimed it. A worker that dies mid-fetch releases the feed when the
+// lease runs out.
+const feedClaimLease = 10 * time.Minute
+
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// intervalHint returns the refresh interval the publisher asks for through
+// <ttl> or the syndication module's updatePeriod/updateFrequency, or zero if
+// the feed gives no hint.
+func intervalHint(rss *RSSFeed) time.Duration {
+	if ttl, err := strconv.Atoi(strings.TrimSpace(rss.Channel.TTL)); err == nil && ttl > 0 {
+		return time.Duration(ttl) * time.Minute
+	}
+	period, ok := updatePeriods[strings.ToLower(strings.TrimSpace(rss.Channel.UpdatePeriod))]
+	if !ok {
+		return 0
+	}
+	frequency, err := strconv.Atoi(strings.TrimSpace(rss.Channel.UpdateFrequency))
+	if err != nil || frequency < 1 {
+		frequency = 1
+	}
+	return period / time.Duration(frequency)
+}
+
+// feedInterval picks the refresh interval for a feed: the one set with
+// setinterval, else the publisher's hint, else the agg default.
+func feedInterval(feed database.Feed, fallback time.Duration) time.Duration {
+	if feed.FetchIntervalSeconds.Valid {
+		return time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second
+	}
+	if feed.HintedIntervalSeconds.Valid {
+		return time.Duration(feed.HintedIntervalSeconds.Int32) * time.Second
+	}
+	return fallback
+}
+
+// nextFetchTime schedules the next fetch one interval from now, pushed past
+// any hours (GMT) and days listed in the feed's skipHours and skipDays.
+func nextFetchTime(now time.Time, interval time.Duration, rss *RSSFeed) time.Time {
+	next := now.Add(interval)
+	if rss == nil {
+		return next
+	}
+	skipHours := map[int]bool{}
+	for _, hour := range rss.Channel.SkipHours {
+		if h, err := strconv.Atoi(strings.TrimSpace(hour)); err == nil {
+			skipHours[h%24] = true
+		}
+	}
+	skipDays := map[time.Weekday]bool{}
+	for _, day := range rss.Channel.SkipDays {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(strings.TrimSpace(day), d.String()) {
+				skipDays[d] = true
+			}
+		}
+	}
+	// A week of hours is enough to get past any combination of skips; a feed
+	// that skips everything is fetched anyway rather than never.
+	for i := 0; i < 7*24; i++ {
+		utc := next.UTC()
+		if !skipHours[utc.Hour()] && !skipDays[utc.Weekday()] {
+			return next
+		}
+		next = utc.Truncate(time.Hour).Add(time.Hour)
+	}
+	return now.Add(interval)
+}
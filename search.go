@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+func searchHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "maximum number of results")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(`usage: search [--limit N] <Query> (words, "exact phrases", prefix*, -excluded, OR)`)
+	}
+	if *limit < 1 {
+		return errors.New("limit must be a positive number")
+	}
+	for i, arg := range args {
+		// The shell already stripped the quotes of a quoted argument, so an
+		// argument with spaces in it was meant as a phrase.
+		if strings.ContainsAny(arg, " \t") && !strings.Contains(arg, `"`) {
+			args[i] = `"` + arg + `"`
+		}
+	}
+	query := buildTSQuery(strings.Join(args, " "))
+	if query == "" {
+		return errors.New("search query has no searchable words")
+	}
+	results, err := s.db.SearchPosts(ctx, database.SearchPostsParams{
+		Query:  query,
+		UserID: user.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("no posts found")
+		return nil
+	}
+	for _, post := range results {
+		fmt.Println("========================================")
+		fmt.Printf("Title: %s (rank %.3f)\n", post.Title, post.Rank)
+		fmt.Println("Feed:", post.FeedName, "\nPublished:", post.PublishedAt.Time.UTC().Format(time.DateTime))
+		fmt.Println("URL:", post.Url)
+		fmt.Println("ID:", post.ID)
+	}
+	return nil
+}
+
+// buildTSQuery turns a search string into to_tsquery syntax. Words are
+// ANDed, "quoted phrases" must match in order, a trailing * matches a
+// prefix, a leading - excludes a term and OR between two terms matches
+// either. Everything but letters and digits is dropped, so user input can
+// never produce an invalid tsquery.
+func buildTSQuery(input string) string {
+	var groups [][]string
+	or := false
+	for _, token := range searchTokens(input) {
+		if token == "OR" {
+			or = len(groups) > 0
+			continue
+		}
+		negate := strings.HasPrefix(token, "-")
+		token = strings.TrimPrefix(token, "-")
+		prefix := strings.HasSuffix(token, "*")
+		words := strings.FieldsFunc(token, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if negate {
+			term = "!" + term
+		}
+		if or {
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		} else {
+			groups = append(groups, []string{term})
+		}
+		or = false
+	}
+	terms := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			terms = append(terms, group[0])
+		} else {
+			terms = append(terms, "("+strings.Join(group, " | ")+")")
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// searchTokens splits input on whitespace, keeping "quoted phrases" (with an
+// optional leading - and trailing *) together as one token.
+func searchTokens(input string) []string {
+	var tokens []string
+	var current strings.Builder
+	inPhrase := false
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"':
+			inPhrase = !inPhrase
+			if !inPhrase {
+				if i+1 < len(runes) && runes[i+1] == '*' {
+					current.WriteRune('*')
+					i++
+				}
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		case unicode.IsSpace(r) && !inPhrase:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
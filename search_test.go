@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"single word", "golang", "golang"},
+		{"words are ANDed", "go generics", "go & generics"},
+		{"extra whitespace", "  go \t generics  ", "go & generics"},
+		{"phrase", `"go generics"`, "(go <-> generics)"},
+		{"one-word phrase", `"go"`, "go"},
+		{"prefix", "gener*", "gener:*"},
+		{"phrase prefix", `"go gener"*`, "(go <-> gener:*)"},
+		{"prefix inside phrase", `"go gener*"`, "(go <-> gener:*)"},
+		{"negation", "go -java", "go & !java"},
+		{"negated phrase", `go -"java beans"`, "go & !(java <-> beans)"},
+		{"negated prefix", "go -jav*", "go & !jav:*"},
+		{"OR", "go OR rust", "(go | rust)"},
+		{"OR chain", "go OR rust OR zig", "(go | rust | zig)"},
+		{"OR binds tighter than AND", "web go OR rust", "web & (go | rust)"},
+		{"OR with phrase", `"go generics" OR rust`, "((go <-> generics) | rust)"},
+		{"leading OR is ignored", "OR go", "go"},
+		{"trailing OR is ignored", "go OR", "go"},
+		{"lowercase or is a word", "go or rust", "go & or & rust"},
+		{"punctuation is dropped", "c++ & go!", "c & go"},
+		{"punctuation splits words", "node.js", "(node <-> js)"},
+		{"tsquery syntax is neutralised", "a:* | !b <-> (c)", "a:* & b & c"},
+		{"punctuation only", "!!! & | ()", ""},
+		{"empty", "", ""},
+		{"empty phrase", `""`, ""},
+		{"unterminated phrase", `"go generics`, "(go <-> generics)"},
+		{"unicode letters", "café Привет", "café & Привет"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildTSQuery(tt.input); got != tt.want {
+				t.Errorf("buildTSQuery(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
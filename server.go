@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+const (
+	defaultServeAddr = ":8080"
+	defaultPageSize  = 20
+	maxPageSize      = 100
+
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+type apiUser struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+	APIKey    string     `json:"api_key,omitempty"`
+}
+
+type apiFeed struct {
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	Owner               string     `json:"owner"`
+	LastFetchedAt       *time.Time `json:"last_fetched_at"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+}
+
+type apiFollow struct {
+	FeedID  uuid.UUID `json:"feed_id"`
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	SiteURL string    `json:"site_url,omitempty"`
+}
+
+type apiPost struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description"`
+	Feed        string     `json:"feed"`
+	PublishedAt *time.Time `json:"published_at"`
+	ReadAt      *time.Time `json:"read_at"`
+	StarredAt   *time.Time `json:"starred_at"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+}
+
+type apiPostPage struct {
+	Posts      []apiPost `json:"posts"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	NextOffset *int      `json:"next_offset"`
+}
+
+func serveHandler(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) > 1 {
+		return errors.New("usage: serve [Addr(:8080 or smth)]")
+	}
+	addr := defaultServeAddr
+	if len(cmd.args) == 1 {
+		addr = cmd.args[0]
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           newAPIMux(s),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Feeds added through the API are fetched on behalf of remote clients,
+	// so they must not be able to point the server at internal services.
+	s.fetcher = newFetcher(s.cfg.Fetch, true)
+	fmt.Printf("serving API on %s\n", addr)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("shutting down API server")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultShutdownGrace)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func newAPIMux(s *state) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users", middlewareAPIUser(s, handleGetUsers(s)))
+	mux.HandleFunc("POST /api/users", handleCreateUser(s))
+	mux.HandleFunc("GET /api/feeds", middlewareAPIUser(s, handleGetFeeds(s)))
+	mux.HandleFunc("POST /api/feeds", middlewareAPIUser(s, handleCreateFeed(s)))
+	mux.HandleFunc("GET /api/follows", middlewareAPIUser(s, handleGetFollows(s)))
+	mux.HandleFunc("POST /api/follows", middlewareAPIUser(s, handleCreateFollow(s)))
+	mux.HandleFunc("DELETE /api/follows/{feedID}", middlewareAPIUser(s, handleDeleteFollow(s)))
+	mux.HandleFunc("GET /api/posts", middlewareAPIUser(s, handleGetPosts(s)))
+	mux.HandleFunc("POST /api/posts/{postID}/read", middlewareAPIUser(s, handleMarkRead(s, true)))
+	mux.HandleFunc("DELETE /api/posts/{postID}/read", middlewareAPIUser(s, handleMarkRead(s, false)))
+	mux.HandleFunc("GET /api/timeline/{format}", withQueryAPIKey(middlewareAPIUser(s, handleGetTimeline(s))))
+	return mux
+}
+
+type authedHandler func(w http.ResponseWriter, r *http.Request, user database.User)
+
+// middlewareAPIUser resolves the API key in the Authorization header
+// ("ApiKey <key>" or "Bearer <key>") to a user, the HTTP counterpart of
+// middlewareLoggedIn.
+func middlewareAPIUser(s *state, handler authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := apiKeyFromHeader(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "ApiKey")
+			respondWithError(w, http.StatusUnauthorized, "missing API key")
+			return
+		}
+		user, err := s.db.GetUserByAPIKey(r.Context(), hashAPIKey(key))
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+func apiKeyFromHeader(header string) (string, bool) {
+	scheme, key, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "ApiKey") && !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	key = strings.TrimSpace(key)
+	return key, key != ""
+}
+
+func handleGetUsers(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ database.User) {
+		users, err := s.db.GetUsers(r.Context())
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		resp := make([]apiUser, 0, len(users))
+		for _, user := range users {
+			resp = append(resp, apiUser{ID: user.ID, Name: user.Name, CreatedAt: nullTimePtr(user.CreatedAt)})
+		}
+		respondWithJSON(w, http.StatusOK, resp)
+	}
+}
+
+func handleCreateUser(s *state) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if body.Name == "" {
+			respondWithError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		key, hash, err := newAPIKey()
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		user, err := s.db.CreateUser(r.Context(), database.CreateUserParams{
+			ID:              uuid.New(),
+			CreatedAt:       sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt:       sql.NullTime{Time: time.Now(), Valid: true},
+			Name:            body.Name,
+			ApiKeyHash:      hash,
+			ApiKeyCreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			respondWithError(w, http.StatusConflict, "can't create user")
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, apiUser{
+			ID:        user.ID,
+			Name:      user.Name,
+			CreatedAt: nullTimePtr(user.CreatedAt),
+			APIKey:    key,
+		})
+	}
+}
+
+func handleGetFeeds(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ database.User) {
+		feeds, err := s.db.Feeds(r.Context())
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		resp := make([]apiFeed, 0, len(feeds))
+		for _, feed := range feeds {
+			resp = append(resp, apiFeed{
+				Name:                feed.Name,
+				URL:                 feed.Url,
+				Owner:               feed.Name_2,
+				LastFetchedAt:       nullTimePtr(feed.LastFetchedAt),
+				ConsecutiveFailures: feed.ConsecutiveFailures,
+				LastError:           feed.LastError.String,
+				DisabledAt:          nullTimePtr(feed.DisabledAt),
+			})
+		}
+		respondWithJSON(w, http.StatusOK, resp)
+	}
+}
+
+// handleCreateFeed adds and follows a feed, like addfeed. When a site URL
+// offers several feeds nothing is created and the candidates are returned
+// so the client can retry with one of them.
+func handleCreateFeed(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		var body struct {
+			Name     string `json:"name"`
+			URL      string `json:"url"`
+			NoVerify bool   `json:"no_verify"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if body.URL == "" || body.NoVerify && body.Name == "" {
+			respondWithError(w, http.StatusBadRequest, "url is required, and so is name with no_verify")
+			return
+		}
+		// With no_verify nothing is fetched now, but agg will fetch the URL
+		// later, so it is checked up front in both cases.
+		if err := checkPublicURL(r.Context(), body.URL); err != nil {
+			respondWithError(w, http.StatusBadRequest, "unusable url: "+err.Error())
+			return
+		}
+		feed, err := createFeed(r.Context(), s, user, body.Name, body.URL, !body.NoVerify, func(candidates []feedCandidate) (feedCandidate, error) {
+			urls := make([]string, 0, len(candidates))
+			for _, candidate := range candidates {
+				urls = append(urls, candidate.URL)
+			}
+			return feedCandidate{}, errors.New("several feeds found, pick one of: " + strings.Join(urls, ", "))
+		})
+		if err != nil {
+			respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		_, err = s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, apiFollow{
+			FeedID:  feed.ID,
+			Name:    feed.Name,
+			URL:     feed.Url,
+			SiteURL: feed.SiteUrl.String,
+		})
+	}
+}
+
+func handleGetFollows(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		follows, err := s.db.GetUsersFollowList(r.Context(), user.ID)
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		resp := make([]apiFollow, 0, len(follows))
+		for _, follow := range follows {
+			resp = append(resp, apiFollow{
+				FeedID:  follow.FeedID,
+				Name:    follow.Name,
+				URL:     follow.Url,
+				SiteURL: follow.SiteUrl.String,
+			})
+		}
+		respondWithJSON(w, http.StatusOK, resp)
+	}
+}
+
+func handleCreateFollow(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		var body struct {
+			FeedURL string `json:"feed_url"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		feed, err := s.db.GetFeedbyurl(r.Context(), body.FeedURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		_, err = s.db.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			respondWithError(w, http.StatusConflict, "already following "+feed.Url)
+			return
+		}
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, apiFollow{
+			FeedID:  feed.ID,
+			Name:    feed.Name,
+			URL:     feed.Url,
+			SiteURL: feed.SiteUrl.String,
+		})
+	}
+}
+
+func handleDeleteFollow(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feedID, err := uuid.Parse(r.PathValue("feedID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid feed ID")
+			return
+		}
+		deleted, err := s.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			UserID: user.ID,
+			FeedID: feedID,
+		})
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		if deleted == 0 {
+			respondWithError(w, http.StatusNotFound, "not following this feed")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGetPosts pages through the user's timeline with limit and offset
+// query parameters. unread, starred and archived filter like the getposts
+// flags of the same name.
+func handleGetPosts(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		query := r.URL.Query()
+		limit, err := queryInt(query.Get("limit"), defaultPageSize)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+			return
+		}
+		offset, err := queryInt(query.Get("offset"), 0)
+		if err != nil || offset < 0 {
+			respondWithError(w, http.StatusBadRequest, "offset must be a non-negative number")
+			return
+		}
+		// One extra row tells whether there is a next page.
+		posts, err := s.db.GetPosts(r.Context(), database.GetPostsParams{
+			UserID:          user.ID,
+			UnreadOnly:      query.Get("unread") == "true",
+			StarredOnly:     query.Get("starred") == "true",
+			IncludeArchived: query.Get("archived") == "true",
+			Category:        sql.NullString{String: query.Get("category"), Valid: query.Get("category") != ""},
+			Limit:           int32(limit + 1),
+			Offset:          int32(offset),
+		})
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		page := apiPostPage{Posts: []apiPost{}, Limit: limit, Offset: offset}
+		if len(posts) > limit {
+			posts = posts[:limit]
+			next := offset + limit
+			page.NextOffset = &next
+		}
+		for _, post := range posts {
+			page.Posts = append(page.Posts, apiPost{
+				ID:          post.ID,
+				Title:       post.Title,
+				URL:         post.Url,
+				Description: post.Description.String,
+				Feed:        post.FeedName,
+				PublishedAt: nullTimePtr(post.PublishedAt),
+				ReadAt:      nullTimePtr(post.ReadAt),
+				StarredAt:   nullTimePtr(post.StarredAt),
+				ArchivedAt:  nullTimePtr(post.ArchivedAt),
+			})
+		}
+		respondWithJSON(w, http.StatusOK, page)
+	}
+}
+
+func handleMarkRead(s *state, read bool) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		postID, err := uuid.Parse(r.PathValue("postID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid post ID")
+			return
+		}
+		if read {
+			err = s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+				UserID: user.ID,
+				PostID: postID,
+				ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+			})
+		} else {
+			err = s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+				UserID: user.ID,
+				PostID: postID,
+			})
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			respondWithError(w, http.StatusNotFound, "post not found")
+			return
+		}
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func respondWithInternalError(w http.ResponseWriter, err error) {
+	log.Printf("api: %v", err)
+	respondWithError(w, http.StatusInternalServerError, "internal error")
+}
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	respondWithJSON(w, code, map[string]string{"error": msg})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("api: encoding response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
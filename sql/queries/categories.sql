@@ -0,0 +1,66 @@
+-- name: UpsertCategory :one
+INSERT INTO categories (id, created_at, updated_at, user_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: AddFollowToCategory :exec
+INSERT INTO feed_follow_categories (feed_follow_id, category_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetUserFollowCategories :many
+SELECT feed_follow.feed_id, categories.name
+FROM feed_follow_categories
+INNER JOIN feed_follow ON feed_follow.id = feed_follow_categories.feed_follow_id
+INNER JOIN categories ON categories.id = feed_follow_categories.category_id
+WHERE feed_follow.user_id = $1
+ORDER BY categories.name;
+
+-- name: CreateCategory :one
+INSERT INTO categories (id, created_at, updated_at, user_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetCategoryByName :one
+SELECT *
+FROM categories
+WHERE user_id = $1 AND name = $2;
+
+-- name: GetUserCategories :many
+SELECT categories.id, categories.name, COUNT(feed_follow_categories.feed_follow_id) AS feed_count
+FROM categories
+LEFT JOIN feed_follow_categories ON feed_follow_categories.category_id = categories.id
+WHERE categories.user_id = $1
+GROUP BY categories.id, categories.name
+ORDER BY categories.name;
+
+-- name: RenameCategory :execrows
+UPDATE categories
+SET name = sqlc.arg(new_name), updated_at = sqlc.arg(updated_at)
+WHERE user_id = sqlc.arg(user_id) AND name = sqlc.arg(name);
+
+-- name: DeleteCategory :execrows
+DELETE FROM categories
+WHERE user_id = $1 AND name = $2;
+
+-- name: RemoveFollowFromCategory :execrows
+DELETE FROM feed_follow_categories
+WHERE feed_follow_id = $1 AND category_id = $2;
+
+-- name: ClearFollowCategories :exec
+DELETE FROM feed_follow_categories
+WHERE feed_follow_id = $1;
@@ -0,0 +1,33 @@
+-- name: RecordFeedFetch :exec
+INSERT INTO feed_fetches (id, feed_id, started_at, finished_at, http_status, bytes, item_count, new_posts, updated_posts, not_modified, error)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
+);
+
+-- name: GetFeedFetches :many
+SELECT *
+FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
+
+-- name: GetFeedFetchStats :one
+SELECT
+    COUNT(*) AS fetches,
+    COUNT(*) FILTER (WHERE error IS NULL) AS successes,
+    COUNT(*) FILTER (WHERE not_modified) AS not_modified,
+    COALESCE(SUM(new_posts), 0)::bigint AS new_posts,
+    COALESCE(AVG(EXTRACT(EPOCH FROM finished_at - started_at)), 0)::float8 AS avg_seconds,
+    COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM finished_at - started_at)), 0)::float8 AS p95_seconds
+FROM feed_fetches
+WHERE feed_id = $1 AND started_at >= $2;
\ No newline at end of file
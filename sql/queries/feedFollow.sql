@@ -20,12 +20,17 @@ INNER JOIN feeds ON feeds.id = inserted_feed_follow.feed_id;
 
 
 -- name: GetUsersFollowList :many
-SELECT feeds.name, users.name
+SELECT feeds.name, users.name, feeds.url, feeds.id AS feed_id, feeds.site_url
 FROM feed_follow
 INNER JOIN feeds ON feeds.id = feed_follow.feed_id
 INNER JOIN users ON users.id = feed_follow.user_id
 WHERE feed_follow.user_id = $1;
 
--- name: DeleteFollow :exec
+-- name: GetFeedFollow :one
+SELECT *
+FROM feed_follow
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: DeleteFollow :execrows
 DELETE FROM feed_follow
 WHERE user_id = $1 AND feed_id = $2;
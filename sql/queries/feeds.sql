@@ -1,18 +1,20 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, description, site_url)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8
 )
 RETURNING *;
 
 
 -- name: Feeds :many
-SELECT feeds.name, feeds.url, users.name
+SELECT feeds.name, feeds.url, users.name, feeds.last_fetched_at, feeds.consecutive_failures, feeds.last_error, feeds.next_fetch_at, feeds.disabled_at, feeds.fetch_interval_seconds, feeds.hinted_interval_seconds
 FROM feeds
 INNER JOIN users
 ON users.id = feeds.user_id;
@@ -27,13 +29,67 @@ WHERE url = $1;
 UPDATE feeds
 SET
     updated_at =      $1,
-    last_fetched_at = $2
-WHERE id = $3;
+    last_fetched_at = $2,
+    last_error = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = $3,
+    etag = $4,
+    last_modified = $5,
+    hinted_interval_seconds = $6
+WHERE id = $7;
+
 
+-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    last_error = $2,
+    last_error_at = $1,
+    consecutive_failures = consecutive_failures + 1,
+    next_fetch_at = $3,
+    disabled_at = $4
+WHERE id = $5;
 
--- name: GetNextFeedToFetch :one
 
-SELECT *
+-- name: EnableFeed :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL
+WHERE id = $2;
+
+-- name: ClaimNextFeedToFetch :one
+UPDATE feeds
+SET next_fetch_at = sqlc.arg(lease_until)
+WHERE id = (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now))
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+
+-- name: SetFeedInterval :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    fetch_interval_seconds = $2
+WHERE id = $3;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET
+    updated_at = $1,
+    url = $2
+WHERE id = $3;
+
+-- name: GetSchedulerLag :one
+SELECT COALESCE(EXTRACT(EPOCH FROM sqlc.arg(now)::timestamp - MIN(last_fetched_at)), 0)::float8 AS lag_seconds
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+WHERE disabled_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,40 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = EXCLUDED.read_at;
+
+-- name: MarkPostUnread :exec
+UPDATE post_states
+SET read_at = NULL
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: StarPost :exec
+INSERT INTO post_states (user_id, post_id, starred_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = EXCLUDED.starred_at;
+
+-- name: UnstarPost :exec
+UPDATE post_states
+SET starred_at = NULL
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: ArchivePost :exec
+INSERT INTO post_states (user_id, post_id, archived_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET archived_at = EXCLUDED.archived_at;
+
+-- name: UnarchivePost :exec
+UPDATE post_states
+SET archived_at = NULL
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: MarkFeedRead :execrows
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT sqlc.arg(user_id)::uuid, posts.id, sqlc.arg(read_at)::timestamp
+FROM posts
+WHERE posts.feed_id = sqlc.arg(feed_id)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at);
\ No newline at end of file
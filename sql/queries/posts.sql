@@ -1,20 +1,105 @@
--- name: CreatePost :exec
-INSERT INTO posts (id, created_at, updated_at, published_at, title, url, description, feed_id)
-VALUES (
-        $1,
-        $2,
-        $3,
-        $4,
-        $5,
-        $6,
-        $7,
-        $8
-);
+-- name: AdoptLegacyPostGuids :execrows
+UPDATE posts
+SET guid = item.guid
+FROM unnest(sqlc.arg(guids)::text[], sqlc.arg(urls)::text[]) AS item(guid, url)
+WHERE posts.feed_id = sqlc.arg(feed_id)
+  AND posts.guid = posts.url
+  AND posts.url = item.url
+  AND item.guid <> item.url
+  AND NOT EXISTS (
+      SELECT 1
+      FROM posts AS existing
+      WHERE existing.feed_id = posts.feed_id
+        AND existing.guid = item.guid
+  );
+
+-- name: UpsertPosts :many
+INSERT INTO posts (id, created_at, updated_at, published_at, title, url, description, feed_id, published_at_estimated, guid)
+SELECT item.id, sqlc.arg(now)::timestamp, sqlc.arg(now)::timestamp, item.published_at, item.title, item.url, item.description, sqlc.arg(feed_id)::uuid, item.published_at_estimated, item.guid
+FROM unnest(
+        sqlc.arg(ids)::uuid[],
+        sqlc.arg(published_ats)::timestamp[],
+        sqlc.arg(titles)::text[],
+        sqlc.arg(urls)::text[],
+        sqlc.arg(descriptions)::text[],
+        sqlc.arg(published_at_estimated)::boolean[],
+        sqlc.arg(guids)::text[]
+) AS item(id, published_at, title, url, description, published_at_estimated, guid)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET
+    updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = CASE WHEN EXCLUDED.published_at_estimated THEN posts.published_at ELSE EXCLUDED.published_at END,
+    published_at_estimated = posts.published_at_estimated AND EXCLUDED.published_at_estimated
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+   OR posts.url IS DISTINCT FROM EXCLUDED.url
+   OR posts.description IS DISTINCT FROM EXCLUDED.description
+   OR (NOT EXCLUDED.published_at_estimated AND posts.published_at IS DISTINCT FROM EXCLUDED.published_at)
+RETURNING (xmax = 0)::boolean AS inserted;
+
+-- name: SearchPosts :many
+SELECT posts.id, posts.published_at, posts.title, posts.url, feeds.name AS feed_name, ts_rank_cd(posts.search_vector, search_query)::real AS rank
+FROM posts
+JOIN feed_follow ON feed_follow.feed_id = posts.feed_id
+JOIN feeds ON feeds.id = posts.feed_id
+CROSS JOIN to_tsquery('english', sqlc.arg(query)) AS search_query
+WHERE feed_follow.user_id = sqlc.arg(user_id)
+  AND posts.search_vector @@ search_query
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetPosts :many
-SELECT *
+SELECT posts.id, posts.published_at, posts.title, posts.url, posts.description, feeds.name AS feed_name, post_states.read_at, post_states.starred_at, post_states.archived_at
 FROM posts
 JOIN feed_follow ON posts.feed_id = feed_follow.feed_id
-WHERE feed_follow.user_id = $1
+JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follow.user_id
+WHERE feed_follow.user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::boolean OR post_states.read_at IS NULL)
+  AND (NOT sqlc.arg(starred_only)::boolean OR post_states.starred_at IS NOT NULL)
+  AND (sqlc.arg(include_archived)::boolean OR post_states.archived_at IS NULL)
+  AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+        SELECT 1
+        FROM feed_follow_categories
+        INNER JOIN categories ON categories.id = feed_follow_categories.category_id
+        WHERE feed_follow_categories.feed_follow_id = feed_follow.id
+          AND categories.name = sqlc.narg(category)
+  ))
 ORDER BY posts.published_at DESC
-LIMIT $2;
\ No newline at end of file
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: GetFilteredPosts :many
+SELECT posts.id, posts.published_at, posts.title, posts.url, posts.description, feeds.name AS feed_name, posts.feed_id, post_states.read_at, post_states.starred_at, post_states.archived_at
+FROM posts
+JOIN feed_follow ON posts.feed_id = feed_follow.feed_id
+JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follow.user_id
+WHERE feed_follow.user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::boolean OR post_states.read_at IS NULL)
+  AND (NOT sqlc.arg(starred_only)::boolean OR post_states.starred_at IS NOT NULL)
+  AND (sqlc.arg(include_archived)::boolean OR post_states.archived_at IS NULL)
+  AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+        SELECT 1
+        FROM feed_follow_categories
+        INNER JOIN categories ON categories.id = feed_follow_categories.category_id
+        WHERE feed_follow_categories.feed_follow_id = feed_follow.id
+          AND categories.name = sqlc.narg(category)
+  ))
+  AND (sqlc.narg(feed)::text IS NULL OR feeds.url = sqlc.narg(feed) OR feeds.name = sqlc.narg(feed))
+  AND (sqlc.narg(since)::timestamp IS NULL OR posts.published_at >= sqlc.narg(since))
+  AND (sqlc.narg(before)::timestamp IS NULL OR posts.published_at < sqlc.narg(before))
+  AND (sqlc.narg(title)::text IS NULL OR posts.title ILIKE '%' || sqlc.narg(title) || '%')
+  AND (sqlc.narg(cursor_published_at)::timestamp IS NULL OR CASE WHEN sqlc.arg(oldest_first)::boolean
+        THEN (COALESCE(posts.published_at, 'epoch'::timestamp), posts.id) > (sqlc.narg(cursor_published_at), sqlc.narg(cursor_id)::uuid)
+        ELSE (COALESCE(posts.published_at, 'epoch'::timestamp), posts.id) < (sqlc.narg(cursor_published_at), sqlc.narg(cursor_id)::uuid)
+  END)
+ORDER BY
+    CASE WHEN sqlc.arg(oldest_first)::boolean THEN COALESCE(posts.published_at, 'epoch'::timestamp) END ASC,
+    CASE WHEN sqlc.arg(oldest_first)::boolean THEN posts.id END ASC,
+    COALESCE(posts.published_at, 'epoch'::timestamp) DESC,
+    posts.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
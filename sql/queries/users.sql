@@ -1,10 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, api_key_hash, api_key_created_at)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
@@ -13,6 +15,19 @@ SELECT *
 FROM users
 WHERE name = $1;
 
+-- name: GetUserByAPIKey :one
+SELECT *
+FROM users
+WHERE api_key_hash = $1;
+
+-- name: SetUserAPIKey :exec
+UPDATE users
+SET
+    api_key_hash = $1,
+    api_key_created_at = $2,
+    updated_at = $3
+WHERE id = $4;
+
 -- name: Reset :exec
 TRUNCATE users CASCADE;
 
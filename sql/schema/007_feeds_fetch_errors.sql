@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN last_error TEXT,
+ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_error_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN last_error,
+DROP COLUMN consecutive_failures,
+DROP COLUMN last_error_at;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN next_fetch_at TIMESTAMP,
+ADD COLUMN disabled_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN next_fetch_at,
+DROP COLUMN disabled_at;
\ No newline at end of file
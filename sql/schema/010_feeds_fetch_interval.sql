@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN fetch_interval_seconds INTEGER,
+ADD COLUMN hinted_interval_seconds INTEGER;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN fetch_interval_seconds,
+DROP COLUMN hinted_interval_seconds;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_search_vector_idx ON posts USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS posts_search_vector_idx;
+
+ALTER TABLE posts
+DROP COLUMN search_vector;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS categories (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE(user_id, name)
+    );
+
+CREATE TABLE IF NOT EXISTS feed_follow_categories (
+    feed_follow_id UUID NOT NULL REFERENCES feed_follow (id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (feed_follow_id, category_id)
+    );
+
+-- +goose Down
+DROP TABLE feed_follow_categories;
+DROP TABLE categories;
\ No newline at end of file
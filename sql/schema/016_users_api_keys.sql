@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN api_key_hash TEXT UNIQUE,
+ADD COLUMN api_key_created_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN api_key_hash,
+DROP COLUMN api_key_created_at;
\ No newline at end of file
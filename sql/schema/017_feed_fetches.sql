@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS feed_fetches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    feed_id UUID NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    http_status INTEGER,
+    bytes BIGINT,
+    item_count INTEGER,
+    new_posts INTEGER NOT NULL DEFAULT 0,
+    updated_posts INTEGER NOT NULL DEFAULT 0,
+    not_modified BOOLEAN NOT NULL DEFAULT false,
+    error TEXT
+    );
+
+CREATE INDEX IF NOT EXISTS feed_fetches_feed_id_started_at_idx ON feed_fetches (feed_id, started_at DESC);
+
+-- +goose Down
+DROP TABLE feed_fetches;
\ No newline at end of file
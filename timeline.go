@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/yourgfslove/BLOGagregator/internal/database"
+)
+
+const (
+	defaultTimelineLimit = 50
+	maxTimelineLimit     = 500
+)
+
+type timelineRSS struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	AtomNS  string   `xml:"xmlns:atom,attr,omitempty"`
+	Channel struct {
+		Title         string            `xml:"title"`
+		Link          string            `xml:"link"`
+		Description   string            `xml:"description"`
+		LastBuildDate string            `xml:"lastBuildDate"`
+		Self          *timelineAtomLink `xml:"atom:link,omitempty"`
+		Items         []timelineRSSItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type timelineRSSItem struct {
+	Title       string          `xml:"title"`
+	Link        string          `xml:"link"`
+	Description string          `xml:"description,omitempty"`
+	PubDate     string          `xml:"pubDate,omitempty"`
+	GUID        timelineRSSGUID `xml:"guid"`
+	Category    string          `xml:"category,omitempty"`
+}
+
+type timelineRSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type timelineAtom struct {
+	XMLName xml.Name            `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string              `xml:"id"`
+	Title   string              `xml:"title"`
+	Updated string              `xml:"updated"`
+	Author  timelineAtomAuthor  `xml:"author"`
+	Links   []timelineAtomLink  `xml:"link"`
+	Entries []timelineAtomEntry `xml:"entry"`
+}
+
+type timelineAtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type timelineAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type timelineAtomEntry struct {
+	ID        string             `xml:"id"`
+	Title     string             `xml:"title"`
+	Links     []timelineAtomLink `xml:"link"`
+	Published string             `xml:"published,omitempty"`
+	Updated   string             `xml:"updated"`
+	Summary   *timelineAtomText  `xml:"summary,omitempty"`
+	Category  *timelineCategory  `xml:"category,omitempty"`
+}
+
+type timelineAtomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type timelineCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// exportFeedHandler writes the user's timeline, the posts getposts would
+// list, as an RSS 2.0 or Atom document so it can be republished.
+func exportFeedHandler(ctx context.Context, s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("exportfeed", flag.ContinueOnError)
+	format := fs.String("format", "rss", "output format: rss or atom")
+	limit := fs.Int("limit", defaultTimelineLimit, "number of posts to include")
+	link := fs.String("link", "", "URL the feed will be published at")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return errors.New("usage: exportfeed [--format rss|atom] [--limit N] [--link URL] [File]")
+	}
+	if *format == "rss" && *link == "" {
+		// RSS 2.0 requires a channel link; Atom identifies the feed by id.
+		return errors.New("--link is required with --format rss: an RSS channel must link to where it is published")
+	}
+	if *limit < 1 || *limit > maxTimelineLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxTimelineLimit)
+	}
+	posts, err := s.db.GetPosts(ctx, database.GetPostsParams{
+		UserID: user.ID,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	var out io.Writer = os.Stdout
+	if len(args) == 1 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return writeTimeline(out, *format, user, posts, *link)
+}
+
+// handleGetTimeline serves the timeline at /api/timeline/{format}. Feed
+// readers can't set headers, so the route is wrapped in withQueryAPIKey.
+func handleGetTimeline(s *state) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		format := r.PathValue("format")
+		if format != "rss" && format != "atom" {
+			respondWithError(w, http.StatusNotFound, "unknown feed format")
+			return
+		}
+		limit, err := queryInt(r.URL.Query().Get("limit"), defaultTimelineLimit)
+		if err != nil || limit < 1 || limit > maxTimelineLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxTimelineLimit))
+			return
+		}
+		posts, err := s.db.GetPosts(r.Context(), database.GetPostsParams{
+			UserID: user.ID,
+			Limit:  int32(limit),
+		})
+		if err != nil {
+			respondWithInternalError(w, err)
+			return
+		}
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		// The self link leaves out the query string so the key is not
+		// republished along with the feed.
+		self := scheme + "://" + r.Host + r.URL.Path
+		if format == "atom" {
+			w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		}
+		if err := writeTimeline(w, format, user, posts, self); err != nil {
+			log.Printf("api: writing timeline: %v", err)
+		}
+	}
+}
+
+// withQueryAPIKey lets a request authenticate with ?key=<api key> when it
+// carries no Authorization header.
+func withQueryAPIKey(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.URL.Query().Get("key"); key != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "ApiKey "+key)
+		}
+		handler(w, r)
+	}
+}
+
+func writeTimeline(w io.Writer, format string, user database.User, posts []database.GetPostsRow, link string) error {
+	var doc any
+	switch format {
+	case "rss":
+		doc = timelineToRSS(user, posts, link)
+	case "atom":
+		doc = timelineToAtom(user, posts, link)
+	default:
+		return fmt.Errorf("unknown feed format %q: want rss or atom", format)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// postGUID is the item identity used in both formats. Post ids survive
+// upserts, so a reader never sees the same post twice.
+func postGUID(post database.GetPostsRow) string {
+	return "urn:uuid:" + post.ID.String()
+}
+
+func timelineToRSS(user database.User, posts []database.GetPostsRow, link string) timelineRSS {
+	var doc timelineRSS
+	doc.Version = "2.0"
+	doc.Channel.Title = user.Name + " timeline"
+	doc.Channel.Link = link
+	doc.Channel.Description = "Posts from the feeds " + user.Name + " follows"
+	doc.Channel.LastBuildDate = time.Now().UTC().Format(time.RFC1123Z)
+	if link != "" {
+		doc.AtomNS = "http://www.w3.org/2005/Atom"
+		doc.Channel.Self = &timelineAtomLink{Href: link, Rel: "self", Type: "application/rss+xml"}
+	}
+	for _, post := range posts {
+		item := timelineRSSItem{
+			Title:       post.Title,
+			Link:        post.Url,
+			Description: post.Description.String,
+			GUID:        timelineRSSGUID{Value: postGUID(post)},
+			Category:    post.FeedName,
+		}
+		if post.PublishedAt.Valid {
+			item.PubDate = post.PublishedAt.Time.UTC().Format(time.RFC1123Z)
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return doc
+}
+
+func timelineToAtom(user database.User, posts []database.GetPostsRow, link string) timelineAtom {
+	updated := time.Now().UTC()
+	// Posts come newest first, so the first date is the feed's last update.
+	if len(posts) > 0 && posts[0].PublishedAt.Valid {
+		updated = posts[0].PublishedAt.Time.UTC()
+	}
+	doc := timelineAtom{
+		ID:      "urn:uuid:" + user.ID.String(),
+		Title:   user.Name + " timeline",
+		Updated: updated.Format(time.RFC3339),
+		Author:  timelineAtomAuthor{Name: user.Name},
+	}
+	if link != "" {
+		doc.Links = append(doc.Links, timelineAtomLink{Href: link, Rel: "self", Type: "application/atom+xml"})
+	}
+	for _, post := range posts {
+		entry := timelineAtomEntry{
+			ID:       postGUID(post),
+			Title:    post.Title,
+			Links:    []timelineAtomLink{{Href: post.Url, Rel: "alternate"}},
+			Updated:  doc.Updated,
+			Category: &timelineCategory{Term: post.FeedName},
+		}
+		if post.PublishedAt.Valid {
+			entry.Published = post.PublishedAt.Time.UTC().Format(time.RFC3339)
+			entry.Updated = entry.Published
+		}
+		if post.Description.String != "" {
+			entry.Summary = &timelineAtomText{Type: "html", Text: post.Description.String}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return doc
+}